@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"MVP_checklist/internal/delivery"
@@ -14,9 +15,6 @@ import (
 	"MVP_checklist/internal/repository"
 	"MVP_checklist/internal/usecase"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -37,91 +35,63 @@ func main() {
 	}
 	defer dbPool.Close()
 
-	// 2. S3 client
-	s3Endpoint := os.Getenv("S3_ENDPOINT")
-	s3Region := os.Getenv("AWS_REGION")
-	if s3Region == "" {
-		s3Region = "us-east-1"
-	}
-	s3Key := os.Getenv("AWS_ACCESS_KEY_ID")
-	s3Secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
-
-	var opts []func(*config.LoadOptions) error
-	opts = append(opts, config.WithRegion(s3Region))
-
-	// Если есть эндпоинт (LocalStack)
-	if s3Endpoint != "" {
-		customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-			if service == s3.ServiceID {
-				return aws.Endpoint{
-					PartitionID:   "aws",
-					URL:           s3Endpoint,
-					SigningRegion: s3Region,
-				}, nil
-			}
-			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-		})
-		opts = append(opts, config.WithEndpointResolverWithOptions(customResolver))
+	// 2. File storage: S3 when configured, the uploads/ folder otherwise
+	storage, err := infrastructure.NewStorageFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v\n", err)
 	}
 
-	// Если ключи не заданы и мы не в LocalStack, используем "пустые" ключи для предотвращения поиска IMDS роли
-	// Либо, если ключи заданы, используем их
-	if s3Key != "" && s3Secret != "" {
-		opts = append(opts, config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
-			return aws.Credentials{
-				AccessKeyID:     s3Key,
-				SecretAccessKey: s3Secret,
-			}, nil
-		})))
-	} else if s3Endpoint != "" {
-		// Для LocalStack часто подходят любые заглушки
-		opts = append(opts, config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
-			return aws.Credentials{
-				AccessKeyID:     "test",
-				SecretAccessKey: "test",
-			}, nil
-		})))
-	}
+	// 3. Repositories
+	repo := repository.NewPostgresRepository(dbPool)
 
-	s3Config, err := config.LoadDefaultConfig(ctx, opts...)
+	// 4. UseCases
+	templateUC := usecase.NewTemplateUseCase(repo, storage)
+	pipeline, err := domain.ParseStagePipeline(os.Getenv("STAGE_PIPELINE"))
 	if err != nil {
-		log.Fatalf("Unable to load SDK config: %v\n", err)
+		log.Fatalf("Invalid STAGE_PIPELINE: %v\n", err)
 	}
-
-	s3Client := s3.NewFromConfig(s3Config, func(o *s3.Options) {
-		if s3Endpoint != "" {
-			o.UsePathStyle = true
-		}
+	stalePhotos, err := usecase.ParseStalePhotoPolicy(os.Getenv("STALE_PHOTO_POLICY"))
+	if err != nil {
+		log.Fatalf("Invalid STALE_PHOTO_POLICY: %v\n", err)
+	}
+	inspectionUC := usecase.NewInspectionUseCase(repo, storage, usecase.InspectionConfig{
+		Pipeline:               pipeline,
+		IdleTimeout:            envDuration("INSPECTION_IDLE_TIMEOUT", 24*time.Hour),
+		PhotoRetention:         envDuration("ABANDONED_PHOTO_RETENTION", 7*24*time.Hour),
+		PhotoFreshnessMargin:   envDuration("PHOTO_FRESHNESS_MARGIN", 15*time.Minute),
+		StalePhotos:            stalePhotos,
+		PhotoMaxDimension:      envInt("PHOTO_MAX_DIMENSION", 2048),
+		ThumbnailSize:          envInt("PHOTO_THUMBNAIL_SIZE", 320),
+		MinReferenceSimilarity: envFloat("REFERENCE_SIMILARITY_THRESHOLD", 0.5),
+		UploadRetention:        envDuration("UPLOAD_RETENTION", 24*time.Hour),
+	})
+	analyticsUC := usecase.NewAnalyticsUseCase(repo, storage)
+	machineUC := usecase.NewMachineUseCase(repo)
+	authUC := usecase.NewAuthUseCase(repo, usecase.AuthConfig{
+		SessionTTL: envDuration("SESSION_TTL", 12*time.Hour),
 	})
+	auditUC := usecase.NewAuditUseCase(repo)
+	integrityUC := usecase.NewIntegrityUseCase(repo, storage)
+	ocrUC := usecase.NewOCRUseCase()
 
-	// 3. Infrastructure
-	var storage domain.FileStorage
-	if s3Key == "" && s3Endpoint == "" {
-		fmt.Println("Using FileSystem storage (uploads/ folder)")
-		storage = infrastructure.NewFileSystemStorage("uploads")
-	} else {
-		bucketName := os.Getenv("S3_BUCKET_NAME")
-		if bucketName == "" {
-			bucketName = "checklist-photos" // default
+	if login, password := os.Getenv("ADMIN_LOGIN"), os.Getenv("ADMIN_PASSWORD"); login != "" && password != "" {
+		if err := authUC.EnsureSuperuser(ctx, login, password); err != nil {
+			log.Fatalf("Unable to create admin user: %v\n", err)
 		}
-		fmt.Printf("Using S3 storage (bucket: %s)\n", bucketName)
-		storage = infrastructure.NewS3Storage(s3Client, bucketName)
 	}
 
-	// 4. Repositories
-	repo := repository.NewPostgresRepository(dbPool)
-
-	// 5. UseCases
-	templateUC := usecase.NewTemplateUseCase(repo)
-	inspectionUC := usecase.NewInspectionUseCase(repo, storage)
-	analyticsUC := usecase.NewAnalyticsUseCase(repo, storage)
-	ocrUC := usecase.NewOCRUseCase()
+	// Background sweeper for abandoned inspections and scheduled photo cleanup
+	go inspectionUC.RunSweeper(ctx, envDuration("SWEEP_INTERVAL", 15*time.Minute))
 
-	// 6. Delivery
-	adminHandler := delivery.NewAdminHandler(templateUC, analyticsUC)
-	publicHandler := delivery.NewPublicHandler(inspectionUC, ocrUC)
+	// 5. Delivery
+	adminHandler := delivery.NewAdminHandler(templateUC, inspectionUC, analyticsUC, machineUC, authUC, auditUC, integrityUC)
+	publicHandler := delivery.NewPublicHandler(inspectionUC, ocrUC, authUC)
 
-	// 7. Routing
+	// 6. Routing
+	trustedProxies, err := delivery.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v\n", err)
+	}
 	mux := http.NewServeMux()
 
 	// Admin routes
@@ -130,8 +100,8 @@ func main() {
 	// Public routes (for inspectors)
 	mux.Handle("/", publicHandler)
 
-	// Static uploads route (for FileSystem storage)
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	// Stored files for logged-in users (for FileSystem storage)
+	mux.Handle("/uploads/", delivery.UploadsHandler(authUC, "uploads"))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -139,7 +109,43 @@ func main() {
 	}
 
 	fmt.Printf("Server starting on port %s...\n", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	if err := http.ListenAndServe(":"+port, delivery.RealIP(mux, trustedProxies)); err != nil {
 		log.Fatalf("Server failed: %v\n", err)
 	}
 }
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v\n", name, err)
+	}
+	return d
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v\n", name, err)
+	}
+	return n
+}
+
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v\n", name, err)
+	}
+	return f
+}
@@ -1,11 +1,19 @@
 package delivery
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"MVP_checklist/internal/domain"
 	"MVP_checklist/internal/usecase"
@@ -13,38 +21,135 @@ import (
 )
 
 type AdminHandler struct {
-	templateUC  *usecase.TemplateUseCase
-	analyticsUC *usecase.AnalyticsUseCase
+	templateUC   *usecase.TemplateUseCase
+	inspectionUC *usecase.InspectionUseCase
+	analyticsUC  *usecase.AnalyticsUseCase
+	machineUC    *usecase.MachineUseCase
+	authUC       *usecase.AuthUseCase
+	auditUC      *usecase.AuditUseCase
+	integrityUC  *usecase.IntegrityUseCase
 }
 
-func NewAdminHandler(templateUC *usecase.TemplateUseCase, analyticsUC *usecase.AnalyticsUseCase) *AdminHandler {
+func NewAdminHandler(templateUC *usecase.TemplateUseCase, inspectionUC *usecase.InspectionUseCase, analyticsUC *usecase.AnalyticsUseCase, machineUC *usecase.MachineUseCase, authUC *usecase.AuthUseCase, auditUC *usecase.AuditUseCase, integrityUC *usecase.IntegrityUseCase) *AdminHandler {
 	return &AdminHandler{
-		templateUC:  templateUC,
-		analyticsUC: analyticsUC,
+		templateUC:   templateUC,
+		inspectionUC: inspectionUC,
+		analyticsUC:  analyticsUC,
+		machineUC:    machineUC,
+		authUC:       authUC,
+		auditUC:      auditUC,
+		integrityUC:  integrityUC,
 	}
 }
 
+type adminUserKey struct{}
+
+// adminUser returns the admin user authenticated for the request.
+func adminUser(r *http.Request) *domain.AdminUser {
+	user, _ := r.Context().Value(adminUserKey{}).(*domain.AdminUser)
+	return user
+}
+
 func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	switch {
+	case path == "/admin/login" && r.Method == http.MethodGet:
+		h.render(w, r, "login.html", map[string]interface{}{"Next": r.URL.Query().Get("next")})
+		return
+	case path == "/admin/login" && r.Method == http.MethodPost:
+		h.handleLogin(w, r)
+		return
+	case path == "/admin/logout" && r.Method == http.MethodPost:
+		h.handleLogout(w, r)
+		return
+	}
+
+	user, err := h.authUC.AdminFromSession(r.Context(), sessionToken(r, adminSessionCookie))
+	if err != nil {
+		if r.Method == http.MethodGet {
+			http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		} else {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), adminUserKey{}, user))
+	r = withActor(r, domain.ActorAdmin, user.ID, user.Name)
+
+	switch {
+	case path == "/admin/" && r.Method == http.MethodGet:
+		http.Redirect(w, r, "/admin/inspections", http.StatusSeeOther)
 	case path == "/admin/templates" && r.Method == http.MethodGet:
-		h.handleListTemplates(w, r)
+		h.authorize(domain.PermViewInspections, h.handleListTemplates)(w, r)
 	case path == "/admin/templates" && r.Method == http.MethodPost:
-		h.handleCreateTemplate(w, r)
+		h.authorize(domain.PermEditTemplates, h.handleCreateTemplate)(w, r)
+	case path == "/admin/templates/edit" && r.Method == http.MethodGet:
+		h.authorize(domain.PermEditTemplates, h.handleEditTemplate)(w, r)
+	case path == "/admin/templates/references" && r.Method == http.MethodPost:
+		h.authorize(domain.PermEditTemplates, h.handleUploadReferenceImage)(w, r)
+	case path == "/admin/templates/history" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleTemplateHistory)(w, r)
+	case path == "/admin/templates/diff" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleTemplateDiff)(w, r)
+	case path == "/admin/templates/export" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleExportTemplates)(w, r)
+	case strings.HasPrefix(path, "/admin/templates/") && strings.HasSuffix(path, "/activate") && r.Method == http.MethodPost:
+		h.authorize(domain.PermEditTemplates, h.handleActivateTemplate)(w, r)
 	case path == "/admin/inspections" && r.Method == http.MethodGet:
-		h.handleListInspections(w, r)
+		h.authorize(domain.PermViewInspections, h.handleListInspections)(w, r)
 	case strings.HasPrefix(path, "/admin/inspections/") && strings.HasSuffix(path, "/export/csv") && r.Method == http.MethodGet:
-		h.handleExportCSV(w, r)
+		h.authorize(domain.PermExportInspections, h.handleExportCSV)(w, r)
 	case strings.HasPrefix(path, "/admin/inspections/") && strings.HasSuffix(path, "/export/pdf") && r.Method == http.MethodGet:
-		h.handleExportPDF(w, r)
+		h.authorize(domain.PermExportInspections, h.handleExportPDF)(w, r)
+	case strings.HasPrefix(path, "/admin/inspections/") && strings.HasSuffix(path, "/cancel") && r.Method == http.MethodPost:
+		h.authorize(domain.PermCancelInspections, h.handleCancelInspection)(w, r)
+	case strings.HasPrefix(path, "/admin/inspections/") && strings.HasSuffix(path, "/review") && r.Method == http.MethodPost:
+		h.authorize(domain.PermReviewInspections, h.handleReviewInspection)(w, r)
 	case strings.HasPrefix(path, "/admin/inspections/") && r.Method == http.MethodGet:
-		h.handleGetInspectionDetail(w, r)
+		h.authorize(domain.PermViewInspections, h.handleGetInspectionDetail)(w, r)
+	case path == "/admin/machines" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleListMachines)(w, r)
+	case strings.HasPrefix(path, "/admin/machines/") && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleGetMachine)(w, r)
+	case strings.HasPrefix(path, "/admin/machines/") && strings.HasSuffix(path, "/overrides") && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageMachines, h.handleGrantStageOverride)(w, r)
+	case strings.HasPrefix(path, "/admin/machines/") && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageMachines, h.handleUpdateMachine)(w, r)
+	case path == "/admin/inspectors" && r.Method == http.MethodGet:
+		h.authorize(domain.PermManageInspectors, h.handleListInspectors)(w, r)
+	case path == "/admin/inspectors" && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageInspectors, h.handleCreateInspector)(w, r)
+	case strings.HasPrefix(path, "/admin/inspectors/") && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageInspectors, h.handleUpdateInspector)(w, r)
+	case path == "/admin/duplicates" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewInspections, h.handleListDuplicatePhotos)(w, r)
+	case path == "/admin/integrity" && r.Method == http.MethodGet:
+		h.authorize(domain.PermVerifyIntegrity, h.handleVerifyIntegrity)(w, r)
+	case path == "/admin/audit" && r.Method == http.MethodGet:
+		h.authorize(domain.PermViewAudit, h.handleListAudit)(w, r)
+	case path == "/admin/users" && r.Method == http.MethodGet:
+		h.authorize(domain.PermManageAdmins, h.handleListAdminUsers)(w, r)
+	case path == "/admin/users" && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageAdmins, h.handleCreateAdminUser)(w, r)
+	case strings.HasPrefix(path, "/admin/users/") && r.Method == http.MethodPost:
+		h.authorize(domain.PermManageAdmins, h.handleUpdateAdminUser)(w, r)
 	default:
 		http.NotFound(w, r)
 	}
 }
 
-func (h *AdminHandler) render(w http.ResponseWriter, name string, data interface{}) {
+// authorize wraps a route handler with a permission check for the logged-in admin user.
+func (h *AdminHandler) authorize(perm domain.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user := adminUser(r); user == nil || !user.Can(perm) {
+			http.Error(w, "Недостаточно прав", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *AdminHandler) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/admin/"+name)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,12 +160,62 @@ func (h *AdminHandler) render(w http.ResponseWriter, name string, data interface
 		"IsAdmin": true,
 		"Data":    data,
 	}
+	if user := adminUser(r); user != nil {
+		can := make(map[string]bool)
+		for _, perm := range []domain.Permission{domain.PermExportInspections, domain.PermCancelInspections, domain.PermReviewInspections, domain.PermManageMachines, domain.PermEditTemplates, domain.PermManageInspectors, domain.PermManageAdmins, domain.PermViewAudit, domain.PermVerifyIntegrity} {
+			can[string(perm)] = user.Can(perm)
+		}
+		renderData["User"] = user
+		renderData["Can"] = can
+	}
 	err = tmpl.ExecuteTemplate(w, "layout", renderData)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// safeNext keeps the post-login redirect inside the admin panel.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/admin/") || strings.HasPrefix(next, "//") {
+		return "/admin/inspections"
+	}
+	return next
+}
+
+func (h *AdminHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	login := r.FormValue("login")
+	next := r.FormValue("next")
+
+	token, _, err := h.authUC.LoginAdmin(r.Context(), login, r.FormValue("password"), clientIP(r))
+	if err != nil {
+		message := "Неверный логин или пароль"
+		switch {
+		case errors.Is(err, domain.ErrTooManyAttempts):
+			message = "Слишком много попыток входа. Попробуйте через несколько минут."
+		case !errors.Is(err, domain.ErrInvalidCredentials):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.render(w, r, "login.html", map[string]interface{}{
+			"Login": login,
+			"Next":  next,
+			"Error": message,
+		})
+		return
+	}
+
+	setSessionCookie(w, r, adminSessionCookie, token, time.Now().Add(h.authUC.SessionTTL()))
+	http.Redirect(w, r, safeNext(next), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.authUC.Logout(r.Context(), sessionToken(r, adminSessionCookie)); err != nil {
+		log.Printf("Logout error: %v", err)
+	}
+	clearSessionCookie(w, adminSessionCookie)
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
 func (h *AdminHandler) handleExportCSV(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	id, _ := uuid.Parse(parts[3])
@@ -92,13 +247,103 @@ func (h *AdminHandler) handleExportPDF(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AdminHandler) handleListInspections(w http.ResponseWriter, r *http.Request) {
-	inspections, err := h.analyticsUC.ListInspections(r.Context(), nil, nil)
+	// Cancelled and abandoned inspections are hidden unless asked for
+	status := r.URL.Query().Get("status")
+	var filter domain.InspectionFilter
+	switch status {
+	case "":
+		filter.Statuses = []domain.InspectionStatus{domain.StatusInProgress, domain.StatusReturned, domain.StatusCompleted}
+	case "review":
+		filter.AwaitingReview = true
+	case "all":
+	default:
+		filter.Statuses = []domain.InspectionStatus{domain.InspectionStatus(status)}
+	}
+	filter.WrongSubject = r.URL.Query().Get("wrong_subject") != ""
+
+	inspections, err := h.analyticsUC.ListInspections(r.Context(), filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	h.render(w, "inspections.html", inspections)
+	h.render(w, r, "inspections.html", map[string]interface{}{
+		"Inspections":  inspections,
+		"Status":       status,
+		"Statuses":     domain.InspectionStatuses,
+		"WrongSubject": filter.WrongSubject,
+	})
+}
+
+func (h *AdminHandler) handleCancelInspection(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	id, err := uuid.Parse(parts[3])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.inspectionUC.CancelInspection(r.Context(), id, r.FormValue("reason"), adminUser(r).Name)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.NotFound(w, r)
+		case errors.As(err, &validationErr):
+			http.Error(w, validationErr.Message, http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/inspections/"+id.String(), http.StatusSeeOther)
+}
+
+// handleReviewInspection approves an inspection or returns it for rework.
+// Each non-empty rework_<question ID> field sends that answer back.
+func (h *AdminHandler) handleReviewInspection(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	id, err := uuid.Parse(parts[3])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rework []domain.ReworkRequest
+	for name, values := range r.PostForm {
+		questionID, ok := strings.CutPrefix(name, "rework_")
+		if !ok || strings.TrimSpace(values[0]) == "" {
+			continue
+		}
+		qid, err := uuid.Parse(questionID)
+		if err != nil {
+			http.Error(w, "Invalid question ID", http.StatusBadRequest)
+			return
+		}
+		rework = append(rework, domain.ReworkRequest{QuestionID: qid, Comment: values[0]})
+	}
+
+	decision := domain.ReviewDecision(r.FormValue("decision"))
+	err = h.inspectionUC.ReviewInspection(r.Context(), id, adminUser(r), decision, r.FormValue("comment"), rework)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.NotFound(w, r)
+		case errors.As(err, &validationErr):
+			http.Error(w, validationErr.Message, http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/inspections/"+id.String(), http.StatusSeeOther)
 }
 
 func (h *AdminHandler) handleListTemplates(w http.ResponseWriter, r *http.Request) {
@@ -108,7 +353,216 @@ func (h *AdminHandler) handleListTemplates(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	h.render(w, "templates.html", templates)
+	h.render(w, r, "templates.html", map[string]interface{}{
+		"Templates": templates,
+		"Roles":     domain.DefaultStagePipeline,
+	})
+}
+
+// handleExportTemplates writes the active template of a role as a template
+// file, or the templates of every role as a zip of files when no role is given.
+func (h *AdminHandler) handleExportTemplates(w http.ResponseWriter, r *http.Request) {
+	if role := r.URL.Query().Get("role"); role != "" {
+		file, err := h.templateUC.ExportTemplate(r.Context(), domain.Role(role))
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(w, "Шаблон не найден", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err := file.Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", templateFileName(file.Role)))
+		w.Write(data)
+		return
+	}
+
+	files, err := h.templateUC.ExportTemplates(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		data, err := file.Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fw, err := zw.Create(templateFileName(file.Role))
+		if err == nil {
+			_, err = fw.Write(data)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=checklists.zip")
+	w.Write(buf.Bytes())
+}
+
+// templateFileName names template files the way the checklists directory does.
+func templateFileName(role domain.Role) string {
+	return strings.ToLower(string(role)) + ".json"
+}
+
+func (h *AdminHandler) handleEditTemplate(w http.ResponseWriter, r *http.Request) {
+	role := domain.Role(r.URL.Query().Get("role"))
+	if !role.IsValid() {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	template, questions, imageURLs, err := h.templateUC.GetTemplateForEditing(r.Context(), role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if questions == nil {
+		questions = []domain.Question{}
+	}
+
+	h.render(w, r, "template_edit.html", map[string]interface{}{
+		"Role":      role,
+		"Template":  template,
+		"Questions": questions,
+		"ImageURLs": imageURLs,
+	})
+}
+
+func (h *AdminHandler) handleTemplateHistory(w http.ResponseWriter, r *http.Request) {
+	role := domain.Role(r.URL.Query().Get("role"))
+	if !role.IsValid() {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := h.templateUC.ListVersions(r.Context(), role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Versions are newest first, so each one is compared with the next
+	previous := make(map[uuid.UUID]*domain.ChecklistTemplate)
+	for i := 0; i+1 < len(versions); i++ {
+		previous[versions[i].ID] = &versions[i+1]
+	}
+
+	h.render(w, r, "template_history.html", map[string]interface{}{
+		"Role":     role,
+		"Versions": versions,
+		"Previous": previous,
+	})
+}
+
+func (h *AdminHandler) handleTemplateDiff(w http.ResponseWriter, r *http.Request) {
+	fromID, err := uuid.Parse(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	toID, err := uuid.Parse(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.templateUC.DiffTemplates(r.Context(), fromID, toID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "template_diff.html", diff)
+}
+
+func (h *AdminHandler) handleActivateTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/templates/"), "/activate"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.templateUC.RollbackTemplate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/templates/history?role="+url.QueryEscape(string(template.Role)), http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleUploadReferenceImage(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	part, err := formFilePart(r, "image")
+	if err != nil {
+		http.Error(w, "Image is required", http.StatusBadRequest)
+		return
+	}
+	defer part.Close()
+
+	key, url, err := h.templateUC.UploadReferenceImage(r.Context(), part)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Message, http.StatusBadRequest)
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Image is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"key": key, "url": url})
+}
+
+// formFilePart returns the part of the multipart file field name, so the
+// file can be streamed on instead of being buffered by ParseMultipartForm.
+func formFilePart(r *http.Request, name string) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
 }
 
 func (h *AdminHandler) handleGetInspectionDetail(w http.ResponseWriter, r *http.Request) {
@@ -125,7 +579,7 @@ func (h *AdminHandler) handleGetInspectionDetail(w http.ResponseWriter, r *http.
 		return
 	}
 
-	h.render(w, "detail.html", detail)
+	h.render(w, r, "detail.html", detail)
 }
 
 type createTemplateRequest struct {
@@ -139,9 +593,18 @@ func (h *AdminHandler) handleCreateTemplate(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !req.Role.IsValid() {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
 
 	template, err := h.templateUC.CreateTemplate(r.Context(), req.Role, req.Questions)
 	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Message, http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -150,3 +613,302 @@ func (h *AdminHandler) handleCreateTemplate(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(template)
 }
+
+func (h *AdminHandler) handleListMachines(w http.ResponseWriter, r *http.Request) {
+	search := r.URL.Query().Get("q")
+
+	machines, err := h.machineUC.ListMachines(r.Context(), search)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "machines.html", map[string]interface{}{
+		"Machines": machines,
+		"Search":   search,
+	})
+}
+
+func (h *AdminHandler) handleGetMachine(w http.ResponseWriter, r *http.Request) {
+	serial := strings.TrimPrefix(r.URL.Path, "/admin/machines/")
+
+	history, err := h.machineUC.GetMachineHistory(r.Context(), serial)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "machine.html", history)
+}
+
+func (h *AdminHandler) handleUpdateMachine(w http.ResponseWriter, r *http.Request) {
+	serial := strings.TrimPrefix(r.URL.Path, "/admin/machines/")
+
+	var shippedAt *time.Time
+	if v := r.FormValue("shipped_at"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid shipped_at date", http.StatusBadRequest)
+			return
+		}
+		shippedAt = &t
+	}
+
+	machine, err := h.machineUC.UpdateMachine(r.Context(), serial, r.FormValue("model"), r.FormValue("production_order"), shippedAt)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/machines/"+machine.Serial, http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleGrantStageOverride(w http.ResponseWriter, r *http.Request) {
+	serial := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/machines/"), "/overrides")
+	role := domain.Role(r.FormValue("role"))
+
+	_, err := h.machineUC.GrantStageOverride(r.Context(), serial, role, r.FormValue("reason"), adminUser(r).Name)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.NotFound(w, r)
+		case errors.As(err, &validationErr):
+			http.Error(w, validationErr.Message, http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/admin/machines/"+serial, http.StatusSeeOther)
+}
+
+func formRoles(r *http.Request) []domain.Role {
+	var roles []domain.Role
+	for _, v := range r.Form["roles"] {
+		roles = append(roles, domain.Role(v))
+	}
+	return roles
+}
+
+// formErrorMessage returns the text to show above a form that failed to
+// save. Only validation messages are meant for the user; anything else is
+// logged and replaced with the fallback.
+func formErrorMessage(err error, fallback string) string {
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Message
+	}
+	log.Printf("Admin form error: %v", err)
+	return fallback
+}
+
+func (h *AdminHandler) renderInspectors(w http.ResponseWriter, r *http.Request, formError string) {
+	inspectors, err := h.authUC.ListInspectors(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "inspectors.html", map[string]interface{}{
+		"Inspectors": inspectors,
+		"Roles":      domain.DefaultStagePipeline,
+		"Error":      formError,
+	})
+}
+
+func (h *AdminHandler) handleListInspectors(w http.ResponseWriter, r *http.Request) {
+	h.renderInspectors(w, r, "")
+}
+
+func (h *AdminHandler) handleCreateInspector(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.authUC.CreateInspector(r.Context(), r.FormValue("name"), r.FormValue("pin"), formRoles(r))
+	if err != nil {
+		h.renderInspectors(w, r, formErrorMessage(err, "Не удалось добавить исполнителя"))
+		return
+	}
+
+	http.Redirect(w, r, "/admin/inspectors", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleUpdateInspector(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/admin/inspectors/"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.authUC.UpdateInspector(r.Context(), id, formRoles(r), r.FormValue("is_active") == "on", r.FormValue("pin"))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		h.renderInspectors(w, r, formErrorMessage(err, "Не удалось сохранить исполнителя"))
+		return
+	}
+
+	http.Redirect(w, r, "/admin/inspectors", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) renderAdminUsers(w http.ResponseWriter, r *http.Request, formError string) {
+	users, err := h.authUC.ListAdminUsers(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "users.html", map[string]interface{}{
+		"Users": users,
+		"Roles": domain.AdminRoles,
+		"Error": formError,
+	})
+}
+
+func (h *AdminHandler) handleListAdminUsers(w http.ResponseWriter, r *http.Request) {
+	h.renderAdminUsers(w, r, "")
+}
+
+func (h *AdminHandler) handleCreateAdminUser(w http.ResponseWriter, r *http.Request) {
+	_, err := h.authUC.CreateAdminUser(r.Context(), r.FormValue("login"), r.FormValue("name"), r.FormValue("password"), domain.AdminRole(r.FormValue("role")))
+	if err != nil {
+		h.renderAdminUsers(w, r, formErrorMessage(err, "Не удалось добавить пользователя"))
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleUpdateAdminUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/admin/users/"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if id == adminUser(r).ID && (r.FormValue("is_active") != "on" || domain.AdminRole(r.FormValue("role")) != domain.AdminSuperuser) {
+		h.renderAdminUsers(w, r, "Нельзя снять права суперпользователя с самого себя")
+		return
+	}
+
+	_, err = h.authUC.UpdateAdminUser(r.Context(), id, domain.AdminRole(r.FormValue("role")), r.FormValue("is_active") == "on", r.FormValue("password"))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		h.renderAdminUsers(w, r, formErrorMessage(err, "Не удалось сохранить пользователя"))
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *AdminHandler) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Action:     domain.AuditAction(query.Get("action")),
+		EntityType: query.Get("entity_type"),
+		EntityID:   strings.TrimSpace(query.Get("entity_id")),
+		Actor:      strings.TrimSpace(query.Get("actor")),
+	}
+	if v := query.Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		filter.Since = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		// The whole day is included
+		t = t.AddDate(0, 0, 1)
+		filter.Until = &t
+	}
+
+	entries, err := h.auditUC.ListEntries(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "audit.html", map[string]interface{}{
+		"Entries": entries,
+		"Actions": domain.AuditActions,
+		"Filter":  filter,
+		"From":    query.Get("from"),
+		"To":      query.Get("to"),
+	})
+}
+
+func (h *AdminHandler) handleListDuplicatePhotos(w http.ResponseWriter, r *http.Request) {
+	var inspectionID *uuid.UUID
+	if v := r.URL.Query().Get("inspection"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		inspectionID = &id
+	}
+
+	flags, err := h.analyticsUC.ListDuplicatePhotos(r.Context(), inspectionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "duplicates.html", map[string]interface{}{
+		"Duplicates":   flags,
+		"InspectionID": inspectionID,
+	})
+}
+
+func (h *AdminHandler) handleVerifyIntegrity(w http.ResponseWriter, r *http.Request) {
+	var inspectionID *uuid.UUID
+	if v := r.URL.Query().Get("inspection"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		inspectionID = &id
+	}
+
+	report, err := h.integrityUC.VerifyChain(r.Context(), inspectionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "integrity.html", map[string]interface{}{
+		"Report":       report,
+		"InspectionID": inspectionID,
+	})
+}
@@ -1,13 +1,18 @@
 package delivery
 
 import (
+	"bufio"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"MVP_checklist/internal/domain"
 	"MVP_checklist/internal/usecase"
@@ -18,44 +23,73 @@ import (
 type PublicHandler struct {
 	inspectionUC *usecase.InspectionUseCase
 	ocrUC        *usecase.OCRUseCase
+	authUC       *usecase.AuthUseCase
 }
 
-func NewPublicHandler(inspectionUC *usecase.InspectionUseCase, ocrUC *usecase.OCRUseCase) *PublicHandler {
+func NewPublicHandler(inspectionUC *usecase.InspectionUseCase, ocrUC *usecase.OCRUseCase, authUC *usecase.AuthUseCase) *PublicHandler {
 	return &PublicHandler{
 		inspectionUC: inspectionUC,
 		ocrUC:        ocrUC,
+		authUC:       authUC,
 	}
 }
 
+// rolePaths maps the role links handed out to inspectors to domain roles
+var rolePaths = map[string]domain.Role{
+	"/OTK":       domain.RoleOTK,
+	"/pasting":   domain.RoleSticker,
+	"/ads":       domain.RoleAds,
+	"/assembler": domain.RoleAssembler,
+}
+
+func rolePath(role domain.Role) string {
+	for path, r := range rolePaths {
+		if r == role {
+			return path
+		}
+	}
+	return "/"
+}
+
 func (h *PublicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
-
-	// Role mapping from URL to domain.Role
-	roleMap := map[string]domain.Role{
-		"/OTK":       domain.RoleOTK,
-		"/pasting":   domain.RoleSticker,
-		"/ads":       domain.RoleAds,
-		"/assembler": domain.RoleAssembler,
+	if r.Method == http.MethodPost {
+		if inspector := h.currentInspector(r); inspector != nil {
+			r = withActor(r, domain.ActorInspector, inspector.ID, inspector.Name)
+		}
 	}
 
 	switch {
 	case path == "/" && r.Method == http.MethodGet:
 		h.render(w, "role_required.html", nil)
 
-	case roleMap[path] != "" && r.Method == http.MethodGet:
-		role := roleMap[path]
-		h.render(w, "index.html", map[string]interface{}{
-			"Role": role,
-		})
+	case rolePaths[path] != "" && r.Method == http.MethodGet:
+		h.handleRolePage(w, r, rolePaths[path])
 
+	case path == "/login" && r.Method == http.MethodPost:
+		h.handleLogin(w, r)
+	case path == "/logout" && r.Method == http.MethodPost:
+		h.handleLogout(w, r)
 	case path == "/inspections/start" && r.Method == http.MethodPost:
 		h.handleStartInspection(w, r)
 	case path == "/api/ocr" && r.Method == http.MethodPost:
 		h.handleOCR(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/cancel") && r.Method == http.MethodPost:
+		h.handleCancelInspection(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/resume") && r.Method == http.MethodGet:
+		h.handleResumeInspection(w, r)
 	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/question") && r.Method == http.MethodGet:
 		h.handleShowQuestion(w, r)
 	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/answer") && r.Method == http.MethodPost:
 		h.handleSaveAnswer(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/uploads") && r.Method == http.MethodPost:
+		h.handleCreateUpload(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.Contains(path, "/uploads/"):
+		h.handleUpload(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/sign") && r.Method == http.MethodGet:
+		h.handleShowSign(w, r)
+	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/complete") && r.Method == http.MethodPost:
+		h.handleCompleteInspection(w, r)
 	case strings.HasPrefix(path, "/inspections/") && strings.HasSuffix(path, "/success") && r.Method == http.MethodGet:
 		h.handleShowSuccess(w, r)
 	default:
@@ -63,6 +97,115 @@ func (h *PublicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// currentInspector returns the logged-in inspector or nil.
+func (h *PublicHandler) currentInspector(r *http.Request) *domain.Inspector {
+	inspector, err := h.authUC.InspectorFromSession(r.Context(), sessionToken(r, inspectorSessionCookie))
+	if err != nil {
+		return nil
+	}
+	return inspector
+}
+
+// authorizeInspection loads the inspection from the URL and makes sure it
+// belongs to the logged-in inspector. It writes the response and returns
+// false otherwise.
+func (h *PublicHandler) authorizeInspection(w http.ResponseWriter, r *http.Request) (*domain.Inspector, *domain.Inspection, bool) {
+	parts := strings.Split(r.URL.Path, "/")
+	inspectionID, err := uuid.Parse(parts[2])
+	if err != nil {
+		http.Error(w, "Inspection not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	inspection, role, err := h.inspectionUC.GetInspectionWithRole(r.Context(), inspectionID)
+	if err != nil {
+		http.Error(w, "Inspection not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	inspector := h.currentInspector(r)
+	if inspector == nil {
+		http.Redirect(w, r, rolePath(role), http.StatusSeeOther)
+		return nil, nil, false
+	}
+	if inspection.InspectorID == nil || *inspection.InspectorID != inspector.ID {
+		http.Error(w, "Эта проверка начата другим сотрудником", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return inspector, inspection, true
+}
+
+func (h *PublicHandler) handleRolePage(w http.ResponseWriter, r *http.Request, role domain.Role) {
+	inspector := h.currentInspector(r)
+	if inspector == nil {
+		h.render(w, "login.html", map[string]interface{}{
+			"Role": role,
+		})
+		return
+	}
+
+	data, err := h.rolePageData(r, role, inspector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !inspector.CanInspect(role) {
+		data["Error"] = "У вас нет доступа к этапу «" + role.Title() + "». Обратитесь к руководителю."
+	}
+	h.render(w, "index.html", data)
+}
+
+// rolePageData prepares index.html for the inspector, listing their
+// unfinished inspections of the role.
+func (h *PublicHandler) rolePageData(r *http.Request, role domain.Role, inspector *domain.Inspector) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"Role":      role,
+		"Inspector": inspector,
+	}
+	if inspector.CanInspect(role) {
+		open, err := h.inspectionUC.ListOpenInspections(r.Context(), role, inspector)
+		if err != nil {
+			return nil, err
+		}
+		data["Open"] = open
+	}
+	return data, nil
+}
+
+func (h *PublicHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	role := domain.Role(r.FormValue("role"))
+	name := r.FormValue("name")
+
+	token, _, err := h.authUC.LoginInspector(r.Context(), name, r.FormValue("pin"), clientIP(r))
+	if err != nil {
+		message := "Неверное имя или PIN-код"
+		switch {
+		case errors.Is(err, domain.ErrTooManyAttempts):
+			message = "Слишком много попыток входа. Попробуйте через несколько минут."
+		case !errors.Is(err, domain.ErrInvalidCredentials):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.render(w, "login.html", map[string]interface{}{
+			"Role":  role,
+			"Name":  name,
+			"Error": message,
+		})
+		return
+	}
+
+	setSessionCookie(w, r, inspectorSessionCookie, token, time.Now().Add(h.authUC.SessionTTL()))
+	http.Redirect(w, r, rolePath(role), http.StatusSeeOther)
+}
+
+func (h *PublicHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.authUC.Logout(r.Context(), sessionToken(r, inspectorSessionCookie)); err != nil {
+		log.Printf("Logout error: %v", err)
+	}
+	clearSessionCookie(w, inspectorSessionCookie)
+	http.Redirect(w, r, rolePath(domain.Role(r.FormValue("role"))), http.StatusSeeOther)
+}
+
 func (h *PublicHandler) render(w http.ResponseWriter, name string, data interface{}) {
 	tmpl, err := template.ParseFiles("templates/layout.html", "templates/public/"+name)
 	if err != nil {
@@ -90,104 +233,547 @@ func (h *PublicHandler) render(w http.ResponseWriter, name string, data interfac
 func (h *PublicHandler) handleStartInspection(w http.ResponseWriter, r *http.Request) {
 	role := domain.Role(r.FormValue("role"))
 	machineSerial := r.FormValue("machine_serial")
-	inspectorName := r.FormValue("inspector_name")
 
-	inspection, _, err := h.inspectionUC.StartInspection(r.Context(), role, machineSerial, inspectorName)
+	inspector := h.currentInspector(r)
+	if inspector == nil {
+		http.Redirect(w, r, rolePath(role), http.StatusSeeOther)
+		return
+	}
+
+	data, err := h.rolePageData(r, role, inspector)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	data["MachineSerial"] = machineSerial
+
+	// Offer to continue an unfinished inspection of the machine unless the
+	// inspector chose to start over
+	if r.FormValue("new") != "1" {
+		open, err := h.inspectionUC.FindOpenInspection(r.Context(), role, machineSerial, inspector)
+		if err == nil {
+			data["Resume"] = open
+			h.render(w, "index.html", data)
+			return
+		}
+		if !errors.Is(err, domain.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	inspection, _, err := h.inspectionUC.StartInspection(r.Context(), role, machineSerial, inspector)
+	if err != nil {
+		var gateErr *domain.StageGateError
+		var validationErr *domain.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			data["Error"] = validationErr.Message
+		case errors.As(err, &gateErr):
+			var stages []string
+			for _, missing := range gateErr.Missing {
+				stages = append(stages, missing.Title())
+			}
+			data["Error"] = "Нельзя начать проверку аппарата " + gateErr.MachineSerial +
+				": не пройдены этапы " + strings.Join(stages, ", ") + ". Обратитесь к руководителю."
+		case errors.Is(err, domain.ErrForbidden):
+			data["Error"] = "У вас нет доступа к этапу «" + role.Title() + "». Обратитесь к руководителю."
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.render(w, "index.html", data)
+		return
+	}
 
 	http.Redirect(w, r, "/inspections/"+inspection.ID.String()+"/question?step=1", http.StatusSeeOther)
 }
 
-func (h *PublicHandler) handleShowQuestion(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
-	inspectionID, _ := uuid.Parse(parts[2])
-	step, _ := strconv.Atoi(r.URL.Query().Get("step"))
+func (h *PublicHandler) handleResumeInspection(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
+	switch inspection.Status {
+	case domain.StatusInProgress, domain.StatusReturned:
+	case domain.StatusCompleted:
+		http.Redirect(w, r, "/inspections/"+inspection.ID.String()+"/success", http.StatusSeeOther)
+		return
+	default:
+		_, role, _ := h.inspectionUC.GetInspectionWithRole(r.Context(), inspection.ID)
+		http.Redirect(w, r, rolePath(role), http.StatusSeeOther)
+		return
+	}
 
-	inspection, err := h.inspectionUC.GetInspectionByID(r.Context(), inspectionID)
+	step, err := h.inspectionUC.ResumeStep(r.Context(), inspection)
 	if err != nil {
-		http.Error(w, "Inspection not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/inspections/"+inspection.ID.String()+"/question?step="+strconv.Itoa(step), http.StatusSeeOther)
+}
+
+func (h *PublicHandler) handleCancelInspection(w http.ResponseWriter, r *http.Request) {
+	inspector, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
 		return
 	}
 
-	// For MVP, we get all questions and pick the one for the current step
-	_, questions, err := h.inspectionUC.GetQuestionsByTemplateID(r.Context(), inspection.TemplateID)
+	if err := h.inspectionUC.CancelInspection(r.Context(), inspection.ID, r.FormValue("reason"), inspector.Name); err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			step, _ := strconv.Atoi(r.FormValue("step"))
+			h.renderQuestion(w, r, inspection, step, nil, validationErr.Message)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, role, _ := h.inspectionUC.GetInspectionWithRole(r.Context(), inspection.ID)
+	http.Redirect(w, r, rolePath(role), http.StatusSeeOther)
+}
+
+func (h *PublicHandler) handleShowQuestion(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
+	step, _ := strconv.Atoi(r.URL.Query().Get("step"))
+	h.renderQuestion(w, r, inspection, step, nil, "")
+}
+
+// renderQuestion shows a step of the inspection filled with the saved answer,
+// or with the rejected input and the reason when the answer failed validation.
+func (h *PublicHandler) renderQuestion(w http.ResponseWriter, r *http.Request, inspection *domain.Inspection, step int, rejected *usecase.AnswerInput, message string) {
+	// Steps index the questions visible with the answers given so far
+	questions, err := h.inspectionUC.VisibleQuestions(r.Context(), inspection)
 	if err != nil || step > len(questions) || step < 1 {
 		http.Error(w, "Question not found", http.StatusNotFound)
 		return
 	}
 
+	question := questions[step-1]
+	answer, photoURLs, err := h.inspectionUC.GetAnswer(r.Context(), inspection.ID, question)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// After a review only answers sent back for rework can be changed
+	returned := inspection.Status == domain.StatusReturned
+	locked := returned && answer != nil && answer.ReworkComment == ""
+	if rejected != nil && rejected.QuestionID == question.ID {
+		reworkComment := ""
+		if answer != nil {
+			reworkComment = answer.ReworkComment
+		}
+		answer = &domain.InspectionAnswer{
+			Verdict:       rejected.Verdict,
+			Value:         rejected.Value,
+			Comment:       rejected.Comment,
+			ReworkComment: reworkComment,
+		}
+		for _, key := range rejected.KeepPhotos {
+			if _, ok := photoURLs[key]; ok {
+				answer.Photos = append(answer.Photos, domain.AnswerPhoto{Key: key})
+			}
+		}
+	}
+	// New photos stay uploaded when the answer is rejected
+	var uploads []uuid.UUID
+	if rejected != nil && rejected.QuestionID == question.ID {
+		for _, id := range rejected.Uploads {
+			if upload, err := h.inspectionUC.GetUpload(r.Context(), inspection.ID, id); err == nil && upload.Complete() {
+				uploads = append(uploads, id)
+			}
+		}
+	}
+
 	h.render(w, "question.html", map[string]interface{}{
-		"InspectionID":  inspectionID,
-		"MachineSerial": inspection.MachineSerial,
-		"Question":      questions[step-1],
-		"CurrentStep":   step,
-		"TotalSteps":    len(questions),
-		"Progress":      (step * 100) / len(questions),
-		"Role":          inspection.TemplateID, // simplified
+		"InspectionID":   inspection.ID,
+		"MachineSerial":  inspection.MachineSerial,
+		"Question":       question,
+		"Answer":         newAnswerForm(answer, photoURLs),
+		"Uploads":        uploads,
+		"Returned":       returned,
+		"Locked":         locked,
+		"Error":          message,
+		"QualityWarning": rejected == nil && r.URL.Query().Get("quality") != "",
+		"CurrentStep":    step,
+		"PrevStep":       step - 1,
+		"TotalSteps":     len(questions),
+		"Progress":       (step * 100) / len(questions),
+		"Role":           inspection.TemplateID, // simplified
 	})
 }
 
+// answerForm holds a saved answer in the shape question.html fills its inputs from.
+type answerForm struct {
+	Verdict       domain.Verdict
+	Bool          string // "yes", "no" or empty
+	Number        string
+	Choices       map[string]bool
+	Text          string
+	Comment       string
+	Photos        []answerFormPhoto
+	ReworkComment string
+}
+
+type answerFormPhoto struct {
+	Key    string
+	URL    string
+	Issues []domain.PhotoIssue
+}
+
+func newAnswerForm(answer *domain.InspectionAnswer, photoURLs map[string]string) answerForm {
+	form := answerForm{Choices: make(map[string]bool)}
+	if answer == nil {
+		return form
+	}
+
+	form.Verdict = answer.Verdict
+	form.Comment = answer.Comment
+	form.ReworkComment = answer.ReworkComment
+	if v := answer.Value; v != nil {
+		if v.Bool != nil {
+			form.Bool = "no"
+			if *v.Bool {
+				form.Bool = "yes"
+			}
+		}
+		if v.Number != nil {
+			form.Number = strconv.FormatFloat(*v.Number, 'f', -1, 64)
+		}
+		for _, choice := range v.Choices {
+			form.Choices[choice] = true
+		}
+		form.Text = v.Text
+	}
+	for _, p := range answer.Photos {
+		photo := answerFormPhoto{Key: p.Key, URL: photoURLs[p.Key]}
+		if p.Quality != nil {
+			photo.Issues = p.Quality.Issues
+		}
+		form.Photos = append(form.Photos, photo)
+	}
+	return form
+}
+
 func (h *PublicHandler) handleSaveAnswer(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
-	inspectionID, _ := uuid.Parse(parts[2])
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
+	inspectionID := inspection.ID
 
-	err := r.ParseMultipartForm(50 << 20) // 50MB for multiple photos
-	if err != nil {
+	// Photos are uploaded beforehand, the form only references them
+	if err := r.ParseForm(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	questionID, _ := uuid.Parse(r.FormValue("question_id"))
 	step, _ := strconv.Atoi(r.FormValue("step"))
+	verdict := domain.Verdict(r.FormValue("verdict"))
 	comment := r.FormValue("comment")
 
-	var photos [][]byte
-	files := r.MultipartForm.File["photos"]
-	for _, fh := range files {
-		f, _ := fh.Open()
-		data, _ := io.ReadAll(f)
-		photos = append(photos, data)
-		f.Close()
+	value, err := formAnswerValue(r)
+	if err != nil {
+		h.renderQuestion(w, r, inspection, step, nil, "Введите число, например 12.5")
+		return
 	}
 
-	err = h.inspectionUC.SaveAnswer(r.Context(), inspectionID, questionID, comment, photos)
+	input := usecase.AnswerInput{
+		QuestionID: questionID,
+		Verdict:    verdict,
+		Value:      value,
+		Comment:    comment,
+		KeepPhotos: r.PostForm["keep_photos"],
+		Uploads:    formUploadIDs(r.PostForm["upload_ids"]),
+	}
+	issues, err := h.inspectionUC.SaveAnswer(r.Context(), inspectionID, input)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			h.renderQuestion(w, r, inspection, step, &input, validationErr.Message)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Photos accepted despite poor quality are shown again so they can be retaken
+	if len(issues) > 0 {
+		http.Redirect(w, r, "/inspections/"+inspectionID.String()+"/question?step="+strconv.Itoa(step)+"&quality=1", http.StatusSeeOther)
+		return
+	}
+
+	// Check if more questions; the answer may have revealed or hidden some
+	questions, err := h.inspectionUC.VisibleQuestions(r.Context(), inspection)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if more questions
-	inspection, _ := h.inspectionUC.GetInspectionByID(r.Context(), inspectionID)
-	_, questions, _ := h.inspectionUC.GetQuestionsByTemplateID(r.Context(), inspection.TemplateID)
+	// A returned inspection goes straight to the next answer to redo
+	next := step + 1
+	if inspection.Status == domain.StatusReturned {
+		next, err = h.inspectionUC.PendingStep(r.Context(), inspection)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if next == 0 {
+			next = len(questions) + 1
+		}
+	}
 
-	if step >= len(questions) {
-		h.inspectionUC.CompleteInspection(r.Context(), inspectionID)
-		http.Redirect(w, r, "/inspections/"+inspectionID.String()+"/success", http.StatusSeeOther)
+	// After the last answer the inspector signs to complete the inspection
+	if next > len(questions) {
+		if err := h.inspectionUC.CheckCompletion(r.Context(), inspection); err != nil {
+			var validationErr *domain.ValidationError
+			if errors.As(err, &validationErr) {
+				h.renderQuestion(w, r, inspection, len(questions), nil, validationErr.Message)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/inspections/"+inspectionID.String()+"/sign", http.StatusSeeOther)
 	} else {
-		http.Redirect(w, r, "/inspections/"+inspectionID.String()+"/question?step="+strconv.Itoa(step+1), http.StatusSeeOther)
+		http.Redirect(w, r, "/inspections/"+inspectionID.String()+"/question?step="+strconv.Itoa(next), http.StatusSeeOther)
 	}
 }
 
-func (h *PublicHandler) handleShowSuccess(w http.ResponseWriter, r *http.Request) {
+// formUploadIDs parses the upload IDs of new photos, skipping malformed
+// and repeated ones.
+func formUploadIDs(values []string) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, v := range values {
+		id, err := uuid.Parse(v)
+		if err != nil || slices.Contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// uploadStatus is the state of a photo upload reported to the client.
+type uploadStatus struct {
+	ID     uuid.UUID `json:"id"`
+	Size   int64     `json:"size"`
+	Offset int64     `json:"offset"`
+}
+
+func writeUploadStatus(w http.ResponseWriter, status int, upload *domain.PhotoUpload) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(uploadStatus{ID: upload.ID, Size: upload.Size, Offset: upload.Offset})
+}
+
+// handleCreateUpload starts a resumable photo upload. The client announces
+// the photo size in the Upload-Length header, then sends the content with
+// PATCH requests to the returned location.
+func (h *PublicHandler) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Length is required", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.inspectionUC.CreateUpload(r.Context(), inspection, size)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Message, http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/inspections/"+inspection.ID.String()+"/uploads/"+upload.ID.String())
+	writeUploadStatus(w, http.StatusCreated, upload)
+}
+
+// handleUpload serves an upload at /inspections/{id}/uploads/{upload}:
+// GET reports how much was received, PATCH appends the chunk in the body
+// at the offset in the Upload-Offset header, DELETE discards the upload and
+// GET .../content returns a complete upload for previews.
+func (h *PublicHandler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
 	parts := strings.Split(r.URL.Path, "/")
-	inspectionID, _ := uuid.Parse(parts[2])
+	uploadID, err := uuid.Parse(parts[4])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	content := len(parts) == 6 && parts[5] == "content"
+	if len(parts) > 5 && !content {
+		http.NotFound(w, r)
+		return
+	}
+
+	var upload *domain.PhotoUpload
+	switch {
+	case content && r.Method == http.MethodGet:
+		var file io.ReadCloser
+		file, upload, err = h.inspectionUC.OpenUpload(r.Context(), inspection.ID, uploadID)
+		if err == nil {
+			defer file.Close()
+			body := bufio.NewReader(file)
+			head, _ := body.Peek(512)
+			w.Header().Set("Content-Type", http.DetectContentType(head))
+			w.Header().Set("Content-Length", strconv.FormatInt(upload.Size, 10))
+			w.Header().Set("Cache-Control", "private, max-age=86400")
+			io.Copy(w, body)
+			return
+		}
+	case !content && r.Method == http.MethodGet:
+		upload, err = h.inspectionUC.GetUpload(r.Context(), inspection.ID, uploadID)
+	case !content && r.Method == http.MethodPatch:
+		offset, parseErr := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if parseErr != nil {
+			http.Error(w, "Upload-Offset is required", http.StatusBadRequest)
+			return
+		}
+		// The chunk is streamed to storage, so its length must be known upfront
+		if r.ContentLength < 0 {
+			http.Error(w, "Content-Length is required", http.StatusLengthRequired)
+			return
+		}
+		if r.ContentLength > usecase.MaxUploadChunkSize {
+			http.Error(w, "Chunk is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		upload, err = h.inspectionUC.AppendUpload(r.Context(), inspection.ID, uploadID, offset, r.Body, r.ContentLength)
+	case !content && r.Method == http.MethodDelete:
+		if err = h.inspectionUC.DeleteUpload(r.Context(), inspection.ID, uploadID); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var validationErr *domain.ValidationError
+	switch {
+	case err == nil:
+		writeUploadStatus(w, http.StatusOK, upload)
+	case errors.Is(err, domain.ErrUploadOffset):
+		writeUploadStatus(w, http.StatusConflict, upload)
+	case errors.Is(err, domain.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.As(err, &validationErr):
+		http.Error(w, validationErr.Message, http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formAnswerValue reads the typed answer fields rendered by question.html.
+// It returns nil when the form has none of them.
+func formAnswerValue(r *http.Request) (*domain.AnswerValue, error) {
+	var value domain.AnswerValue
+	found := false
+
+	switch r.FormValue("value_bool") {
+	case "yes":
+		b := true
+		value.Bool, found = &b, true
+	case "no":
+		b := false
+		value.Bool, found = &b, true
+	}
+	if raw := strings.TrimSpace(r.FormValue("value_number")); raw != "" {
+		n, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+		if err != nil {
+			return nil, err
+		}
+		value.Number, found = &n, true
+	}
+	if choices := r.PostForm["value_choice"]; len(choices) > 0 {
+		value.Choices, found = choices, true
+	}
+	if text := strings.TrimSpace(r.FormValue("value_text")); text != "" {
+		value.Text, found = text, true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+func (h *PublicHandler) handleShowSign(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
+	if inspection.Status == domain.StatusCompleted {
+		http.Redirect(w, r, "/inspections/"+inspection.ID.String()+"/success", http.StatusSeeOther)
+		return
+	}
+	h.renderSign(w, r, inspection, "")
+}
+
+func (h *PublicHandler) renderSign(w http.ResponseWriter, r *http.Request, inspection *domain.Inspection, message string) {
+	questions, err := h.inspectionUC.VisibleQuestions(r.Context(), inspection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.render(w, "sign.html", map[string]interface{}{
+		"Inspection": inspection,
+		"PrevStep":   len(questions),
+		"Error":      message,
+	})
+}
+
+func (h *PublicHandler) handleCompleteInspection(w http.ResponseWriter, r *http.Request) {
+	inspector, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
+	}
 
-	inspection, role, _ := h.inspectionUC.GetInspectionWithRole(r.Context(), inspectionID)
+	// The signature canvas is posted as a PNG data URL
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.FormValue("signature"), "data:image/png;base64,"))
+	if err != nil {
+		h.renderSign(w, r, inspection, "Не удалось прочитать подпись, распишитесь еще раз")
+		return
+	}
+
+	if err := h.inspectionUC.CompleteInspection(r.Context(), inspection.ID, signature, inspector.Name); err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			h.renderSign(w, r, inspection, validationErr.Message)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/inspections/"+inspection.ID.String()+"/success", http.StatusSeeOther)
+}
 
-	// Map domain.Role back to URL path
-	rolePathMap := map[domain.Role]string{
-		domain.RoleOTK:       "/OTK",
-		domain.RoleSticker:   "/pasting",
-		domain.RoleAds:       "/ads",
-		domain.RoleAssembler: "/assembler",
+func (h *PublicHandler) handleShowSuccess(w http.ResponseWriter, r *http.Request) {
+	_, inspection, ok := h.authorizeInspection(w, r)
+	if !ok {
+		return
 	}
 
+	_, role, _ := h.inspectionUC.GetInspectionWithRole(r.Context(), inspection.ID)
+
 	h.render(w, "success.html", map[string]interface{}{
 		"Inspection": inspection,
-		"NextURL":    rolePathMap[role],
+		"NextURL":    rolePath(role),
 	})
 }
 
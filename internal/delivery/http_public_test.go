@@ -0,0 +1,146 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"MVP_checklist/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+// answerRepo keeps one inspection in memory, enough to save answers to it.
+// Methods the tests do not reach are left to the embedded nil interface.
+type answerRepo struct {
+	domain.ChecklistRepository
+	session    domain.Session
+	inspector  domain.Inspector
+	inspection domain.Inspection
+	questions  []domain.Question
+	answers    []domain.InspectionAnswer
+}
+
+func (r *answerRepo) GetSession(ctx context.Context, tokenHash string) (*domain.Session, error) {
+	if tokenHash != r.session.TokenHash {
+		return nil, domain.ErrNotFound
+	}
+	session := r.session
+	return &session, nil
+}
+
+func (r *answerRepo) GetInspectorByID(ctx context.Context, id uuid.UUID) (*domain.Inspector, error) {
+	inspector := r.inspector
+	return &inspector, nil
+}
+
+func (r *answerRepo) GetInspectionByID(ctx context.Context, id uuid.UUID) (*domain.Inspection, error) {
+	if id != r.inspection.ID {
+		return nil, domain.ErrNotFound
+	}
+	inspection := r.inspection
+	return &inspection, nil
+}
+
+func (r *answerRepo) GetTemplateByID(ctx context.Context, id uuid.UUID) (*domain.ChecklistTemplate, error) {
+	return &domain.ChecklistTemplate{ID: id, Role: domain.RoleOTK}, nil
+}
+
+func (r *answerRepo) GetQuestionsByTemplateID(ctx context.Context, templateID uuid.UUID) ([]domain.Question, error) {
+	return r.questions, nil
+}
+
+func (r *answerRepo) GetInspectionAnswers(ctx context.Context, inspectionID uuid.UUID) ([]domain.InspectionAnswer, error) {
+	return r.answers, nil
+}
+
+func (r *answerRepo) SaveAnswer(ctx context.Context, answer *domain.InspectionAnswer, cleanupAfter time.Time) error {
+	r.answers = append(r.answers, *answer)
+	return nil
+}
+
+func (r *answerRepo) CreateAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	return nil
+}
+
+// sessionHash is how sessions are looked up by token.
+func sessionHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHandleSaveAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		question domain.Question
+		form     url.Values
+		want     *domain.AnswerValue
+	}{
+		{
+			name:     "Multiple choice",
+			question: domain.Question{Type: domain.QuestionMultiChoice, Config: domain.QuestionConfig{Options: []string{"Скол", "Царапина", "Нет"}}},
+			form:     url.Values{"value_choice": {"Скол", "Царапина"}},
+			want:     &domain.AnswerValue{Choices: []string{"Скол", "Царапина"}},
+		},
+		{
+			name:     "Number with comma",
+			question: domain.Question{Type: domain.QuestionNumber},
+			form:     url.Values{"value_number": {"12,5"}},
+			want:     &domain.AnswerValue{Number: func() *float64 { n := 12.5; return &n }()},
+		},
+		{
+			name:     "Plain verdict",
+			question: domain.Question{Type: domain.QuestionPhoto},
+			form:     url.Values{"verdict": {string(domain.VerdictPass)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspectorID, token := uuid.New(), "token"
+			question := tt.question
+			question.ID, question.Order, question.Text = uuid.New(), 1, "Вопрос"
+			repo := &answerRepo{
+				session:    domain.Session{TokenHash: sessionHash(token), Kind: domain.SessionInspector, SubjectID: inspectorID, ExpiresAt: time.Now().Add(time.Hour)},
+				inspector:  domain.Inspector{ID: inspectorID, Name: "Иванов", IsActive: true},
+				inspection: domain.Inspection{ID: uuid.New(), TemplateID: uuid.New(), InspectorID: &inspectorID, Status: domain.StatusInProgress, StartedAt: time.Now()},
+				questions:  []domain.Question{question, {ID: uuid.New(), Type: domain.QuestionText, Order: 2, Text: "Следующий"}},
+			}
+			handler := NewPublicHandler(
+				usecase.NewInspectionUseCase(repo, nil, usecase.InspectionConfig{}),
+				nil,
+				usecase.NewAuthUseCase(repo, usecase.AuthConfig{}),
+			)
+
+			form := tt.form
+			form.Set("question_id", question.ID.String())
+			form.Set("step", "1")
+			req := httptest.NewRequest(http.MethodPost, "/inspections/"+repo.inspection.ID.String()+"/answer", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(&http.Cookie{Name: inspectorSessionCookie, Value: token})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusSeeOther {
+				t.Fatalf("expected redirect, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if want := "/inspections/" + repo.inspection.ID.String() + "/question?step=2"; rec.Header().Get("Location") != want {
+				t.Errorf("expected redirect to %s, got %s", want, rec.Header().Get("Location"))
+			}
+			if len(repo.answers) != 1 {
+				t.Fatalf("expected one saved answer, got %d", len(repo.answers))
+			}
+			if got := repo.answers[0].Value; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected value %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package delivery
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	inspectorSessionCookie = "inspector_session"
+	adminSessionCookie     = "admin_session"
+)
+
+func sessionToken(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, name, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// withActor attaches the logged-in user to the request context so that
+// changes made while serving it are attributed in the audit log.
+func withActor(r *http.Request, kind domain.ActorKind, id uuid.UUID, name string) *http.Request {
+	return r.WithContext(domain.WithActor(r.Context(), domain.Actor{
+		Kind:      kind,
+		ID:        &id,
+		Name:      name,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Request:   r.Method + " " + r.URL.Path,
+	}))
+}
+
+// clientIP returns the address the request came from; RealIP resolves it
+// for requests through a trusted proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TrustedProxies are the networks of the reverse proxies in front of the
+// server.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma-separated list of addresses and CIDR
+// ranges.
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy address %q: %w", v, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy network %q: %w", v, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range p {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP sets the remote address of requests that come through a trusted
+// proxy to the client address from X-Forwarded-For: the last one not itself
+// a trusted proxy, since proxies append to the header. Requests from
+// anywhere else keep their address, as clients can set the header to anything.
+func RealIP(next http.Handler, proxies TrustedProxies) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 && proxies.contains(clientIP(r)) {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := strings.TrimSpace(hops[i])
+				if _, err := netip.ParseAddr(hop); err != nil {
+					break
+				}
+				if !proxies.contains(hop) || i == 0 {
+					r.RemoteAddr = net.JoinHostPort(hop, "0")
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{name: "Direct request", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "Forged header from a client", remote: "203.0.113.7:5000", forwarded: []string{"1.2.3.4"}, want: "203.0.113.7"},
+		{name: "Through a trusted proxy", remote: "10.1.2.3:443", forwarded: []string{"198.51.100.20"}, want: "198.51.100.20"},
+		{name: "Forged hop before the proxy", remote: "10.1.2.3:443", forwarded: []string{"1.2.3.4, 198.51.100.20"}, want: "198.51.100.20"},
+		{name: "Chain of trusted proxies", remote: "10.1.2.3:443", forwarded: []string{"198.51.100.20, 192.168.1.5", "10.9.9.9"}, want: "198.51.100.20"},
+		{name: "Malformed hop", remote: "10.1.2.3:443", forwarded: []string{"garbage"}, want: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			var got string
+			RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}), proxies).ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("expected client IP %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, s := range []string{"10.0.0.0/33", "not-an-ip"} {
+		if _, err := ParseTrustedProxies(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+	if proxies, err := ParseTrustedProxies(""); err != nil || len(proxies) != 0 {
+		t.Errorf("expected no proxies for an empty list, got %v, %v", proxies, err)
+	}
+}
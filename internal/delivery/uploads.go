@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"MVP_checklist/internal/usecase"
+)
+
+// UploadsHandler serves the files of the local storage under /uploads/ to
+// logged-in admin users and inspectors. Directories are not listed, and the
+// chunks of photo uploads are left to the upload endpoint of their inspection.
+func UploadsHandler(authUC *usecase.AuthUseCase, dir string) http.Handler {
+	root := http.Dir(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := authUC.AdminFromSession(r.Context(), sessionToken(r, adminSessionCookie)); err != nil {
+			if _, err := authUC.InspectorFromSession(r.Context(), sessionToken(r, inspectorSessionCookie)); err != nil {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/uploads/"))
+		if strings.HasPrefix(name, "/"+usecase.UploadChunkPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := root.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"MVP_checklist/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+func TestUploadsHandler(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photos/a.jpg", usecase.UploadChunkPrefix + "u/0-c"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inspectorID, token := uuid.New(), "token"
+	repo := &answerRepo{
+		session:   domain.Session{TokenHash: sessionHash(token), Kind: domain.SessionInspector, SubjectID: inspectorID, ExpiresAt: time.Now().Add(time.Hour)},
+		inspector: domain.Inspector{ID: inspectorID, Name: "Иванов", IsActive: true},
+	}
+	handler := UploadsHandler(usecase.NewAuthUseCase(repo, usecase.AuthConfig{}), dir)
+
+	tests := []struct {
+		name     string
+		path     string
+		loggedIn bool
+		want     int
+	}{
+		{name: "Logged-in inspector", path: "/uploads/photos/a.jpg", loggedIn: true, want: http.StatusOK},
+		{name: "Anonymous", path: "/uploads/photos/a.jpg", want: http.StatusForbidden},
+		{name: "Directory listing", path: "/uploads/photos/", loggedIn: true, want: http.StatusNotFound},
+		{name: "Upload chunk", path: "/uploads/" + usecase.UploadChunkPrefix + "u/0-c", loggedIn: true, want: http.StatusNotFound},
+		{name: "Missing file", path: "/uploads/photos/b.jpg", loggedIn: true, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.loggedIn {
+				req.AddCookie(&http.Cookie{Name: inspectorSessionCookie, Value: token})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -2,6 +2,14 @@ package domain
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +24,110 @@ const (
 	RoleAssembler Role = "ASSEMBLER"
 )
 
+// Title returns the name of the role as shown to users.
+func (r Role) Title() string {
+	switch r {
+	case RoleOTK:
+		return "ОТК"
+	case RoleSticker:
+		return "Оклейка"
+	case RoleAds:
+		return "Реклама"
+	case RoleAssembler:
+		return "Сборка"
+	}
+	return string(r)
+}
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleOTK, RoleSticker, RoleAds, RoleAssembler:
+		return true
+	}
+	return false
+}
+
+// StagePipeline is the order of production stages. Starting an inspection for
+// a role requires a completed, passing inspection for every role before it.
+type StagePipeline []Role
+
+var DefaultStagePipeline = StagePipeline{RoleAssembler, RoleSticker, RoleAds, RoleOTK}
+
+// ParseStagePipeline parses a comma separated list of roles, e.g. "ASSEMBLER,STICKER,ADS,OTK".
+// An empty string yields the default pipeline.
+func ParseStagePipeline(s string) (StagePipeline, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultStagePipeline, nil
+	}
+
+	var pipeline StagePipeline
+	seen := make(map[Role]bool)
+	for _, part := range strings.Split(s, ",") {
+		role := Role(strings.ToUpper(strings.TrimSpace(part)))
+		if !role.IsValid() {
+			return nil, fmt.Errorf("unknown role %q in stage pipeline", part)
+		}
+		if seen[role] {
+			return nil, fmt.Errorf("role %s appears twice in stage pipeline", role)
+		}
+		seen[role] = true
+		pipeline = append(pipeline, role)
+	}
+	return pipeline, nil
+}
+
+// Prerequisites returns the roles that must pass before the given role.
+// Roles outside of the pipeline have no prerequisites.
+func (p StagePipeline) Prerequisites(role Role) []Role {
+	for i, r := range p {
+		if r == role {
+			return p[:i]
+		}
+	}
+	return nil
+}
+
+// StageOverride lets an admin allow one inspection of a role for a machine
+// even though its previous stages have not passed.
+type StageOverride struct {
+	ID           uuid.UUID
+	MachineID    uuid.UUID
+	Role         Role
+	Reason       string
+	GrantedBy    string
+	InspectionID *uuid.UUID // inspection started with this override, nil while unused
+	CreatedAt    time.Time
+}
+
+// StageGateError is returned when an inspection is started before the
+// previous production stages of the machine have passed.
+type StageGateError struct {
+	MachineSerial string
+	Role          Role
+	Missing       []Role
+}
+
+func (e *StageGateError) Error() string {
+	return fmt.Sprintf("machine %s has not passed stages %v required for %s", e.MachineSerial, e.Missing, e.Role)
+}
+
+// ErrNotFound is wrapped by repository errors when the requested entity does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrUploadOffset is returned for a chunk that does not continue an upload
+// where it stands.
+var ErrUploadOffset = errors.New("upload offset mismatch")
+
+// ValidationError reports input the user has to correct. Message is shown to
+// the user as is.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
 type ChecklistTemplate struct {
 	ID        uuid.UUID
 	Role      Role
@@ -24,10 +136,95 @@ type ChecklistTemplate struct {
 	CreatedAt time.Time
 }
 
+type QuestionChangeKind string
+
+const (
+	QuestionAdded   QuestionChangeKind = "added"
+	QuestionRemoved QuestionChangeKind = "removed"
+	QuestionChanged QuestionChangeKind = "changed"
+)
+
+// QuestionChange describes how a question differs between two template
+// versions. Old is nil for added questions and New is nil for removed ones.
+type QuestionChange struct {
+	Kind              QuestionChangeKind
+	Old               *Question
+	New               *Question
+	TextChanged       bool
+	OrderChanged      bool
+	TypeChanged       bool // type, type-specific settings or show-if condition
+	PhotosChanged     bool // MinPhotos or MaxPhotos
+	RequiredChanged   bool
+	ReferencesChanged bool
+}
+
+type TemplateDiff struct {
+	From    ChecklistTemplate
+	To      ChecklistTemplate
+	Changes []QuestionChange
+}
+
+type QuestionType string
+
+const (
+	QuestionPhoto        QuestionType = "photo"
+	QuestionYesNo        QuestionType = "yes_no"
+	QuestionNumber       QuestionType = "number"
+	QuestionSingleChoice QuestionType = "single_choice"
+	QuestionMultiChoice  QuestionType = "multi_choice"
+	QuestionText         QuestionType = "text"
+)
+
+func (t QuestionType) IsValid() bool {
+	switch t {
+	case QuestionPhoto, QuestionYesNo, QuestionNumber, QuestionSingleChoice, QuestionMultiChoice, QuestionText:
+		return true
+	}
+	return false
+}
+
+// QuestionConfig holds the type-specific settings of a question. It is stored
+// as JSON with the question.
+type QuestionConfig struct {
+	// Min and Max are the tolerance of a number question; a value outside it fails the check.
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Unit string   `json:"unit,omitempty"`
+	// Options are the answers of a choice question; choosing any of FailOptions fails the check.
+	Options     []string `json:"options,omitempty"`
+	FailOptions []string `json:"fail_options,omitempty"`
+	// PhotoQuality sets the quality checks of photos attached to the answer; nil means none.
+	PhotoQuality *PhotoQualityConfig `json:"photo_quality,omitempty"`
+}
+
+// PhotoQualityConfig holds the thresholds of automatic photo quality
+// checks. A zero threshold disables its check.
+type PhotoQualityConfig struct {
+	MinSharpness  float64 `json:"min_sharpness,omitempty"`  // variance of the Laplacian
+	MinBrightness float64 `json:"min_brightness,omitempty"` // mean luminance, 0 to 255
+	MaxClipped    float64 `json:"max_clipped,omitempty"`    // share of blown-out pixels, 0 to 1
+	// Reject refuses a photo that fails a check; otherwise it is saved with a warning
+	Reject bool `json:"reject,omitempty"`
+}
+
+// QuestionCondition shows a question only when the answer to an earlier
+// question matches every criterion that is set.
+type QuestionCondition struct {
+	// Question is the Order of the earlier question in the same template.
+	Question int       `json:"question"`
+	Verdicts []Verdict `json:"verdicts,omitempty"`
+	Bool     *bool     `json:"bool,omitempty"`
+	// Choices matches when any of them was chosen.
+	Choices []string `json:"choices,omitempty"`
+}
+
 type Question struct {
 	ID              uuid.UUID
 	TemplateID      uuid.UUID
 	Text            string
+	Type            QuestionType
+	Config          QuestionConfig
+	ShowIf          *QuestionCondition // nil for questions that are always asked
 	Order           int
 	MinPhotos       int
 	MaxPhotos       int
@@ -36,35 +233,500 @@ type Question struct {
 	CreatedAt       time.Time
 }
 
+// AnswerValue is the typed value of an answer; only the field matching the
+// question type is set.
+type AnswerValue struct {
+	Bool    *bool    `json:"bool,omitempty"`
+	Number  *float64 `json:"number,omitempty"`
+	Choices []string `json:"choices,omitempty"`
+	Text    string   `json:"text,omitempty"`
+}
+
+// Title renders the value for the admin panel.
+func (v *AnswerValue) Title() string {
+	switch {
+	case v.Bool != nil:
+		if *v.Bool {
+			return "Да"
+		}
+		return "Нет"
+	case v.Number != nil:
+		return strconv.FormatFloat(*v.Number, 'f', -1, 64)
+	case len(v.Choices) > 0:
+		return strings.Join(v.Choices, ", ")
+	default:
+		return v.Text
+	}
+}
+
 type InspectionStatus string
 
 const (
 	StatusInProgress InspectionStatus = "in_progress"
 	StatusCompleted  InspectionStatus = "completed"
+	// StatusCancelled is set when an inspection is explicitly cancelled with a reason
+	StatusCancelled InspectionStatus = "cancelled"
+	// StatusAbandoned is set by the sweeper when an inspection stays idle too long
+	StatusAbandoned InspectionStatus = "abandoned"
+	// StatusReturned is set when a reviewer sends answers back for rework
+	StatusReturned InspectionStatus = "returned"
+)
+
+var InspectionStatuses = []InspectionStatus{StatusInProgress, StatusReturned, StatusCompleted, StatusCancelled, StatusAbandoned}
+
+func (s InspectionStatus) Title() string {
+	switch s {
+	case StatusInProgress:
+		return "В работе"
+	case StatusCompleted:
+		return "Завершена"
+	case StatusCancelled:
+		return "Отменена"
+	case StatusAbandoned:
+		return "Брошена"
+	case StatusReturned:
+		return "На доработке"
+	default:
+		return string(s)
+	}
+}
+
+// Verdict is the outcome of a single checklist answer or of the whole inspection.
+type Verdict string
+
+const (
+	VerdictPass          Verdict = "pass"
+	VerdictFail          Verdict = "fail"
+	VerdictNotApplicable Verdict = "na"
+)
+
+func (v Verdict) IsValid() bool {
+	switch v {
+	case VerdictPass, VerdictFail, VerdictNotApplicable:
+		return true
+	}
+	return false
+}
+
+type Inspector struct {
+	ID        uuid.UUID
+	Name      string
+	PINHash   string
+	Roles     []Role
+	IsActive  bool
+	CreatedAt time.Time
+}
+
+func (i *Inspector) HasRole(role Role) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Inspector) CanInspect(role Role) bool {
+	return i.IsActive && i.HasRole(role)
+}
+
+type AdminRole string
+
+const (
+	AdminViewer         AdminRole = "viewer"
+	AdminQAManager      AdminRole = "qa_manager"
+	AdminTemplateEditor AdminRole = "template_editor"
+	AdminSuperuser      AdminRole = "superuser"
+)
+
+var AdminRoles = []AdminRole{AdminViewer, AdminQAManager, AdminTemplateEditor, AdminSuperuser}
+
+func (r AdminRole) IsValid() bool {
+	for _, role := range AdminRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (r AdminRole) Title() string {
+	switch r {
+	case AdminViewer:
+		return "Наблюдатель"
+	case AdminQAManager:
+		return "Руководитель ОТК"
+	case AdminTemplateEditor:
+		return "Редактор шаблонов"
+	case AdminSuperuser:
+		return "Суперпользователь"
+	}
+	return string(r)
+}
+
+type Permission string
+
+const (
+	PermViewInspections   Permission = "inspections.view"
+	PermExportInspections Permission = "inspections.export"
+	PermCancelInspections Permission = "inspections.cancel"
+	PermReviewInspections Permission = "inspections.review"
+	PermManageMachines    Permission = "machines.manage"
+	PermEditTemplates     Permission = "templates.edit"
+	PermManageInspectors  Permission = "inspectors.manage"
+	PermManageAdmins      Permission = "admins.manage"
+	PermViewAudit         Permission = "audit.view"
+	PermVerifyIntegrity   Permission = "integrity.verify"
 )
 
+var adminRolePermissions = map[AdminRole][]Permission{
+	AdminViewer:         {PermViewInspections},
+	AdminQAManager:      {PermViewInspections, PermExportInspections, PermCancelInspections, PermReviewInspections, PermVerifyIntegrity, PermManageMachines, PermManageInspectors},
+	AdminTemplateEditor: {PermViewInspections, PermEditTemplates},
+}
+
+func (r AdminRole) Can(p Permission) bool {
+	if r == AdminSuperuser {
+		return true
+	}
+	for _, granted := range adminRolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+type AdminUser struct {
+	ID           uuid.UUID
+	Login        string
+	Name         string
+	PasswordHash string
+	Role         AdminRole
+	IsActive     bool
+	CreatedAt    time.Time
+}
+
+func (u *AdminUser) Can(p Permission) bool {
+	return u.IsActive && u.Role.Can(p)
+}
+
+type SessionKind string
+
+const (
+	SessionInspector SessionKind = "inspector"
+	SessionAdmin     SessionKind = "admin"
+)
+
+// Session is a login session. Only the SHA-256 hash of the cookie token is stored.
+type Session struct {
+	TokenHash string
+	Kind      SessionKind
+	SubjectID uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+type Machine struct {
+	ID              uuid.UUID
+	Serial          string
+	Model           string
+	ProductionOrder string
+	CreatedAt       time.Time
+	ShippedAt       *time.Time
+}
+
+type MachineSummary struct {
+	Machine          Machine
+	InspectionCount  int
+	LastInspectionAt *time.Time
+}
+
+// MachineInspection is an entry of a machine's history: the inspection and
+// the role of the checklist it was performed with.
+type MachineInspection struct {
+	Inspection Inspection
+	Role       Role
+}
+
+type MachineHistory struct {
+	Machine     Machine
+	Inspections []MachineInspection
+	Overrides   []StageOverride
+}
+
 type Inspection struct {
 	ID            uuid.UUID
 	TemplateID    uuid.UUID
+	MachineID     uuid.UUID
 	MachineSerial string
+	InspectorID   *uuid.UUID // nil for inspections recorded before inspector accounts
 	InspectorName string
 	Status        InspectionStatus
+	Verdict       Verdict // empty until the inspection is completed
 	StartedAt     time.Time
 	FinishedAt    *time.Time
+	// StageOverrideID is set when the inspection was allowed to skip stage gating
+	StageOverrideID *uuid.UUID
+	// CancelReason and CancelledBy are set for cancelled inspections
+	CancelReason string
+	CancelledBy  string
+	// ApprovedBy and ApprovedAt are set once a reviewer approves the inspection
+	ApprovedBy string
+	ApprovedAt *time.Time
+	// SignatureKey, SignedBy and SignedAt record the inspector's handwritten
+	// signature given on completion
+	SignatureKey string
+	SignedBy     string
+	SignedAt     *time.Time
+}
+
+// Signature is the inspector's handwritten signature stored as a PNG image.
+type Signature struct {
+	Key      string
+	SignedBy string
+	SignedAt time.Time
+}
+
+// AwaitingReview reports whether the inspection is completed and waits for a
+// reviewer's decision.
+func (i *Inspection) AwaitingReview() bool {
+	return i.Status == StatusCompleted && i.ApprovedAt == nil
+}
+
+// InspectionFilter narrows a list of inspections. Zero fields match any inspection.
+type InspectionFilter struct {
+	Role          *Role
+	Statuses      []InspectionStatus // any of
+	InspectorID   *uuid.UUID
+	MachineSerial string
+	// AwaitingReview matches completed inspections without an approval
+	AwaitingReview bool
+	// WrongSubject matches inspections with photos unlike the reference images
+	WrongSubject bool
+}
+
+// InspectionProgress is an inspection with the number of visible questions
+// answered so far and of answers waiting for rework.
+type InspectionProgress struct {
+	Inspection Inspection
+	Answered   int
+	Total      int
+	Rework     int
 }
 
 type InspectionAnswer struct {
 	ID           uuid.UUID
 	InspectionID uuid.UUID
 	QuestionID   uuid.UUID
+	Verdict      Verdict
+	Value        *AnswerValue // nil for photo questions
+	Comment      string
+	Photos       []AnswerPhoto
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// ReworkComment is the reviewer's remark when the answer was sent back;
+	// ReworkRequestedAt is when. Both are cleared by the next review.
+	ReworkComment     string
+	ReworkRequestedAt *time.Time
+}
+
+// AnswerPhoto is a photo attached to an answer with the EXIF metadata read
+// from it on upload. Key is the upright JPEG shown to viewers; photos saved
+// before processing was added have no original or thumbnail.
+type AnswerPhoto struct {
+	Key          string        `json:"key"`
+	OriginalKey  string        `json:"original_key,omitempty"`  // as uploaded
+	ThumbnailKey string        `json:"thumbnail_key,omitempty"` // small JPEG for lists
+	Quality      *PhotoQuality `json:"quality,omitempty"`       // nil for photos saved before it was measured
+	// ReferenceSimilarity scores from 0 to 1 how much the photo resembles
+	// the closest reference image of the question; nil if it has none
+	ReferenceSimilarity *float64 `json:"reference_similarity,omitempty"`
+	// WrongSubject is set when the similarity is low enough that the photo
+	// possibly shows something else
+	WrongSubject bool `json:"wrong_subject,omitempty"`
+	// PerceptualHash fingerprints what the photo shows, so near-identical
+	// photos have hashes differing in few bits; zero if not computed
+	PerceptualHash int64 `json:"phash,omitempty"`
+	PhotoMetadata
+	URL          string `json:"-"` // viewable links, set for display
+	ThumbnailURL string `json:"-"`
+}
+
+// PhotoQuality holds image quality metrics of a photo, measured on the
+// photo scaled down to a fixed size.
+type PhotoQuality struct {
+	Sharpness  float64      `json:"sharpness"`  // variance of the Laplacian of luminance
+	Brightness float64      `json:"brightness"` // mean luminance, 0 to 255
+	Clipped    float64      `json:"clipped"`    // share of blown-out pixels, 0 to 1
+	Issues     []PhotoIssue `json:"issues,omitempty"`
+}
+
+// ClippedPercent is the share of blown-out pixels in percent.
+func (q PhotoQuality) ClippedPercent() float64 {
+	return q.Clipped * 100
+}
+
+// PhotoIssue is a quality check a photo failed.
+type PhotoIssue string
+
+const (
+	PhotoBlurry      PhotoIssue = "blurry"
+	PhotoDark        PhotoIssue = "dark"
+	PhotoOverexposed PhotoIssue = "overexposed"
+)
+
+func (i PhotoIssue) Title() string {
+	switch i {
+	case PhotoBlurry:
+		return "снимок размыт"
+	case PhotoDark:
+		return "слишком темно"
+	case PhotoOverexposed:
+		return "пересвечено"
+	}
+	return string(i)
+}
+
+// PhotoMetadata is what the EXIF data of a photo tells about how it was
+// taken. Zero fields were not present in the image.
+type PhotoMetadata struct {
+	TakenAt     *time.Time `json:"taken_at,omitempty"`
+	Device      string     `json:"device,omitempty"`      // camera make and model
+	Orientation int        `json:"orientation,omitempty"` // EXIF orientation, 1 to 8
+	Latitude    *float64   `json:"latitude,omitempty"`
+	Longitude   *float64   `json:"longitude,omitempty"`
+	// Stale is set when the photo was taken before the inspection started,
+	// earlier than the allowed margin
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Coordinates formats the GPS position as "lat, lon" in decimal degrees,
+// or returns "" when the photo has none.
+func (m PhotoMetadata) Coordinates() string {
+	if m.Latitude == nil || m.Longitude == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f, %.6f", *m.Latitude, *m.Longitude)
+}
+
+// SimilarityPercent is the reference similarity in whole percent, zero
+// if the photo was not compared.
+func (p AnswerPhoto) SimilarityPercent() int {
+	if p.ReferenceSimilarity == nil {
+		return 0
+	}
+	return int(math.Round(*p.ReferenceSimilarity * 100))
+}
+
+// NeedsRework reports whether the answer was sent back and not saved again since.
+func (a *InspectionAnswer) NeedsRework() bool {
+	return a.ReworkRequestedAt != nil && a.UpdatedAt.Before(*a.ReworkRequestedAt)
+}
+
+type ReviewDecision string
+
+const (
+	ReviewApproved ReviewDecision = "approved"
+	ReviewReturned ReviewDecision = "returned"
+)
+
+func (d ReviewDecision) Title() string {
+	switch d {
+	case ReviewApproved:
+		return "Принята"
+	case ReviewReturned:
+		return "Возвращена на доработку"
+	}
+	return string(d)
+}
+
+// ReworkRequest asks the inspector to redo the answer to a question.
+type ReworkRequest struct {
+	QuestionID uuid.UUID `json:"question_id"`
+	Comment    string    `json:"comment"`
+}
+
+// InspectionReview is a reviewer's decision on a completed inspection. An
+// inspection returned for rework is reviewed again once it is completed.
+type InspectionReview struct {
+	ID           uuid.UUID
+	InspectionID uuid.UUID
+	Decision     ReviewDecision
+	ReviewerID   uuid.UUID
+	ReviewerName string
 	Comment      string
-	Photos       []string
+	Rework       []ReworkRequest // only for returned inspections
 	CreatedAt    time.Time
 }
 
 type InspectionDetail struct {
 	Inspection Inspection
 	Answers    []InspectionAnswerDetail
+	Reviews    []InspectionReview // oldest first
+	// SignatureURL links to the signature image of a signed inspection
+	SignatureURL string
+	Seal         *InspectionSeal // nil until the inspection is completed
+	// Duplicates are the flags on photos shared with other machines
+	Duplicates []DuplicatePhoto
+	// WrongSubjectPhotos counts photos unlike the reference images of their question
+	WrongSubjectPhotos int
+}
+
+// PhotoRef is a stored photo and the inspection it is attached to.
+type PhotoRef struct {
+	Key           string
+	ThumbnailKey  string
+	InspectionID  uuid.UUID
+	MachineSerial string
+	URL           string // viewable links, set for display
+	ThumbnailURL  string
+}
+
+// HashedPhoto is a photo with its perceptual hash.
+type HashedPhoto struct {
+	PhotoRef
+	Hash int64
+}
+
+// DuplicatePhoto flags a newly uploaded photo that is near-identical to one
+// already attached to an inspection of another machine.
+type DuplicatePhoto struct {
+	ID        uuid.UUID
+	Photo     PhotoRef
+	Match     PhotoRef
+	Distance  int // bits in which the perceptual hashes differ
+	CreatedAt time.Time
+}
+
+// PhotoUpload is a photo sent to an inspection in chunks, so an upload cut
+// off by a dropped connection resumes from Offset instead of starting over.
+// Answers reference completed uploads by ID.
+type PhotoUpload struct {
+	ID           uuid.UUID
+	InspectionID uuid.UUID
+	Size         int64 // total bytes announced when the upload was created
+	Offset       int64 // bytes received so far
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Complete reports whether all bytes of the upload have been received.
+func (u PhotoUpload) Complete() bool {
+	return u.Offset == u.Size
+}
+
+// PhotoUploadChunk is a received part of an upload, kept as a storage object.
+type PhotoUploadChunk struct {
+	Offset int64 // of the chunk's first byte in the photo
+	Size   int64
+	Key    string
 }
 
 type InspectionAnswerDetail struct {
@@ -72,6 +734,197 @@ type InspectionAnswerDetail struct {
 	Answer   InspectionAnswer
 }
 
+type ActorKind string
+
+const (
+	ActorAdmin     ActorKind = "admin"
+	ActorInspector ActorKind = "inspector"
+	ActorSystem    ActorKind = "system" // background jobs and tools without a logged-in user
+)
+
+func (k ActorKind) Title() string {
+	switch k {
+	case ActorAdmin:
+		return "Администратор"
+	case ActorInspector:
+		return "Исполнитель"
+	case ActorSystem:
+		return "Система"
+	}
+	return string(k)
+}
+
+// Actor is who performs a change, with the metadata of the request it came in.
+type Actor struct {
+	Kind      ActorKind
+	ID        *uuid.UUID
+	Name      string
+	IP        string
+	UserAgent string
+	Request   string // method and path
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor for the audit log.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or the system actor.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Kind: ActorSystem, Name: "system"}
+}
+
+type AuditAction string
+
+const (
+	AuditTemplateCreate     AuditAction = "template.create"
+	AuditTemplateDeactivate AuditAction = "template.deactivate"
+	AuditTemplateActivate   AuditAction = "template.activate"
+	AuditTemplateDelete     AuditAction = "template.delete"
+	AuditTemplateExport     AuditAction = "template.export"
+	AuditInspectionStart    AuditAction = "inspection.start"
+	AuditInspectionAnswer   AuditAction = "inspection.answer"
+	AuditInspectionComplete AuditAction = "inspection.complete"
+	AuditInspectionCancel   AuditAction = "inspection.cancel"
+	AuditInspectionAbandon  AuditAction = "inspection.abandon"
+	AuditInspectionReview   AuditAction = "inspection.review"
+	AuditInspectionExport   AuditAction = "inspection.export"
+	AuditMachineUpdate      AuditAction = "machine.update"
+	AuditStageOverride      AuditAction = "machine.override"
+	AuditInspectorCreate    AuditAction = "inspector.create"
+	AuditInspectorUpdate    AuditAction = "inspector.update"
+	AuditAdminUserCreate    AuditAction = "admin_user.create"
+	AuditAdminUserUpdate    AuditAction = "admin_user.update"
+)
+
+var AuditActions = []AuditAction{
+	AuditTemplateCreate, AuditTemplateDeactivate, AuditTemplateActivate, AuditTemplateDelete, AuditTemplateExport,
+	AuditInspectionStart, AuditInspectionAnswer, AuditInspectionComplete, AuditInspectionCancel, AuditInspectionAbandon, AuditInspectionReview, AuditInspectionExport,
+	AuditMachineUpdate, AuditStageOverride,
+	AuditInspectorCreate, AuditInspectorUpdate, AuditAdminUserCreate, AuditAdminUserUpdate,
+}
+
+func (a AuditAction) Title() string {
+	switch a {
+	case AuditTemplateCreate:
+		return "Создание шаблона"
+	case AuditTemplateDeactivate:
+		return "Деактивация шаблона"
+	case AuditTemplateActivate:
+		return "Активация шаблона"
+	case AuditTemplateDelete:
+		return "Удаление шаблона"
+	case AuditTemplateExport:
+		return "Выгрузка шаблонов"
+	case AuditInspectionStart:
+		return "Начало проверки"
+	case AuditInspectionAnswer:
+		return "Ответ"
+	case AuditInspectionComplete:
+		return "Завершение проверки"
+	case AuditInspectionCancel:
+		return "Отмена проверки"
+	case AuditInspectionAbandon:
+		return "Проверка брошена"
+	case AuditInspectionReview:
+		return "Решение по проверке"
+	case AuditInspectionExport:
+		return "Выгрузка проверки"
+	case AuditMachineUpdate:
+		return "Изменение аппарата"
+	case AuditStageOverride:
+		return "Разрешение проверки вне очереди этапов"
+	case AuditInspectorCreate:
+		return "Добавление исполнителя"
+	case AuditInspectorUpdate:
+		return "Изменение исполнителя"
+	case AuditAdminUserCreate:
+		return "Добавление пользователя панели"
+	case AuditAdminUserUpdate:
+		return "Изменение пользователя панели"
+	}
+	return string(a)
+}
+
+// Entity types recorded in the audit log
+const (
+	AuditEntityTemplate   = "template"
+	AuditEntityInspection = "inspection"
+	AuditEntityMachine    = "machine" // identified by serial
+	AuditEntityInspector  = "inspector"
+	AuditEntityAdminUser  = "admin_user"
+)
+
+// AuditEntry is an append-only record of a change or export. Before and
+// After are JSON snapshots of the entity; Before is empty for new entities
+// and After for deleted ones.
+type AuditEntry struct {
+	ID         uuid.UUID
+	Actor      Actor
+	Action     AuditAction
+	EntityType string
+	EntityID   string
+	Before     []byte
+	After      []byte
+	CreatedAt  time.Time
+}
+
+// AuditFilter narrows the audit log. Zero fields match any entry.
+type AuditFilter struct {
+	Action     AuditAction
+	EntityType string
+	EntityID   string
+	Actor      string // part of the actor name
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+}
+
+// InspectionSeal links a completed inspection into a tamper-evident hash
+// chain. Each completion appends a seal; an inspection completed again after
+// rework gets a new one.
+type InspectionSeal struct {
+	Sequence     int64 // position in the chain, starting at 1
+	InspectionID uuid.UUID
+	ContentHash  string // SHA-256 of the canonical inspection record
+	PrevHash     string // hash of the previous seal, empty for the first
+	Hash         string
+	CreatedAt    time.Time
+}
+
+// ChainHash computes the hash of a seal from its position, inspection,
+// content hash and the hash of the previous seal.
+func ChainHash(sequence int64, inspectionID uuid.UUID, contentHash, prevHash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s\n%s", sequence, inspectionID, contentHash, prevHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// IntegrityProblem is a broken link in the seal chain or an inspection whose
+// record no longer matches its seal.
+type IntegrityProblem struct {
+	Sequence      int64 // zero for a completed inspection without a seal
+	InspectionID  uuid.UUID
+	MachineSerial string
+	Message       string
+}
+
+// IntegrityReport is the result of verifying the seal chain.
+type IntegrityReport struct {
+	Seals       int // seals checked
+	Inspections int // inspection records recomputed
+	Problems    []IntegrityProblem
+	CheckedAt   time.Time
+}
+
+func (r *IntegrityReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
 type ChecklistRepository interface {
 	CreateTemplate(ctx context.Context, template *ChecklistTemplate) error
 	CreateQuestion(ctx context.Context, question *Question) error
@@ -81,15 +934,104 @@ type ChecklistRepository interface {
 	GetQuestionsByTemplateID(ctx context.Context, templateID uuid.UUID) ([]Question, error)
 	DeleteTemplateByRole(ctx context.Context, role Role) error
 	DeactivateTemplatesByRole(ctx context.Context, role Role) error
+	ListTemplatesByRole(ctx context.Context, role Role) ([]ChecklistTemplate, error)
+	// ActivateTemplate makes the template the only active version of its role.
+	ActivateTemplate(ctx context.Context, role Role, id uuid.UUID) error
 	CreateInspection(ctx context.Context, inspection *Inspection) error
 	GetInspectionByID(ctx context.Context, id uuid.UUID) (*Inspection, error)
-	ListInspections(ctx context.Context, role *Role, status *InspectionStatus) ([]Inspection, error)
+	ListInspections(ctx context.Context, filter InspectionFilter) ([]Inspection, error)
 	GetInspectionAnswers(ctx context.Context, inspectionID uuid.UUID) ([]InspectionAnswer, error)
-	SaveAnswer(ctx context.Context, answer *InspectionAnswer) error
-	CompleteInspection(ctx context.Context, id uuid.UUID) error
+	// SaveAnswer creates or replaces the answer; files of photos it no longer
+	// has are scheduled for deletion after cleanupAfter
+	SaveAnswer(ctx context.Context, answer *InspectionAnswer, cleanupAfter time.Time) error
+	// CompleteInspection completes an open inspection; ErrNotFound if it is no longer open
+	CompleteInspection(ctx context.Context, id uuid.UUID, verdict Verdict, signature Signature) error
+	// SealInspection appends a seal for the inspection to the chain. Seals
+	// are appended one at a time so each links to the latest one.
+	SealInspection(ctx context.Context, inspectionID uuid.UUID, contentHash string) (*InspectionSeal, error)
+	// GetInspectionSeal returns the latest seal of the inspection.
+	GetInspectionSeal(ctx context.Context, inspectionID uuid.UUID) (*InspectionSeal, error)
+	// ListInspectionSeals returns the whole chain in order.
+	ListInspectionSeals(ctx context.Context) ([]InspectionSeal, error)
+	// CancelInspection cancels an in-progress or returned inspection; ErrNotFound if there is none
+	CancelInspection(ctx context.Context, id uuid.UUID, reason, cancelledBy string) error
+	// ReviewInspection records the decision on an inspection awaiting review
+	// and approves or returns it; ErrNotFound if it does not await review
+	ReviewInspection(ctx context.Context, review *InspectionReview) error
+	ListInspectionReviews(ctx context.Context, inspectionID uuid.UUID) ([]InspectionReview, error)
+	// AbandonInspections marks in-progress inspections without activity since
+	// idleSince as abandoned and schedules their photos for deletion after cleanupAfter
+	AbandonInspections(ctx context.Context, idleSince, cleanupAfter time.Time) ([]uuid.UUID, error)
+	// FindSimilarPhotos returns photos of other machines whose perceptual
+	// hash matches the given one in at least one 16-bit quarter; callers
+	// check the full distance
+	FindSimilarPhotos(ctx context.Context, hash int64, excludeSerial string) ([]HashedPhoto, error)
+	// CreateDuplicatePhoto records a flag; flagging the same pair again is a no-op
+	CreateDuplicatePhoto(ctx context.Context, flag *DuplicatePhoto) error
+	// ListDuplicatePhotos returns flags newest first, only those involving
+	// the inspection on either side if one is given
+	ListDuplicatePhotos(ctx context.Context, inspectionID *uuid.UUID) ([]DuplicatePhoto, error)
+	CreatePhotoUpload(ctx context.Context, upload *PhotoUpload) error
+	GetPhotoUpload(ctx context.Context, id uuid.UUID) (*PhotoUpload, error)
+	// AppendPhotoUpload records a stored chunk if the upload has exactly
+	// chunk.Offset bytes and the chunk fits its size, returning the new
+	// offset; ErrNotFound otherwise
+	AppendPhotoUpload(ctx context.Context, id uuid.UUID, chunk PhotoUploadChunk) (int64, error)
+	// ListPhotoUploadChunks returns the chunks of an upload in order
+	ListPhotoUploadChunks(ctx context.Context, id uuid.UUID) ([]PhotoUploadChunk, error)
+	// DeletePhotoUploads deletes uploads and schedules their chunks for deletion from storage
+	DeletePhotoUploads(ctx context.Context, ids []uuid.UUID) error
+	// DeleteStalePhotoUploads deletes uploads without activity since before,
+	// scheduling their chunks for deletion, and returns how many were deleted
+	DeleteStalePhotoUploads(ctx context.Context, before time.Time) (int64, error)
+	ListDueStorageCleanup(ctx context.Context, now time.Time, limit int) ([]string, error)
+	MarkStorageCleanedUp(ctx context.Context, key string) error
+	// PostponeStorageCleanup moves the deletion of an object that failed to
+	// delete to until, so it does not hold up the objects due after it
+	PostponeStorageCleanup(ctx context.Context, key string, until time.Time) error
+	EnsureMachine(ctx context.Context, serial string) (*Machine, error)
+	GetMachineBySerial(ctx context.Context, serial string) (*Machine, error)
+	UpdateMachine(ctx context.Context, machine *Machine) error
+	ListMachines(ctx context.Context, search string) ([]MachineSummary, error)
+	ListMachineInspections(ctx context.Context, machineID uuid.UUID) ([]MachineInspection, error)
+	CreateStageOverride(ctx context.Context, override *StageOverride) error
+	GetUnusedStageOverride(ctx context.Context, machineID uuid.UUID, role Role) (*StageOverride, error)
+	ListStageOverrides(ctx context.Context, machineID uuid.UUID) ([]StageOverride, error)
+	CreateInspector(ctx context.Context, inspector *Inspector) error
+	UpdateInspector(ctx context.Context, inspector *Inspector) error
+	GetInspectorByID(ctx context.Context, id uuid.UUID) (*Inspector, error)
+	GetInspectorByName(ctx context.Context, name string) (*Inspector, error)
+	ListInspectors(ctx context.Context) ([]Inspector, error)
+	CreateAdminUser(ctx context.Context, user *AdminUser) error
+	UpdateAdminUser(ctx context.Context, user *AdminUser) error
+	GetAdminUserByID(ctx context.Context, id uuid.UUID) (*AdminUser, error)
+	GetAdminUserByLogin(ctx context.Context, login string) (*AdminUser, error)
+	ListAdminUsers(ctx context.Context) ([]AdminUser, error)
+	CreateAuditEntry(ctx context.Context, entry *AuditEntry) error
+	ListAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, tokenHash string) (*Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteExpiredSessions deletes sessions expired by now and returns how many
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
 }
 
 type FileStorage interface {
-	Upload(ctx context.Context, bucket, key string, data []byte) (string, error)
+	// Upload streams the object from r. size is -1 when unknown; an empty
+	// contentType is detected from the key's extension or the content
+	Upload(ctx context.Context, bucket, key string, r io.Reader, size int64, contentType string) (string, error)
 	GetURL(ctx context.Context, bucket, key string) (string, error)
+	// Open streams the object's content; the caller closes it
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// Stat returns ErrNotFound for a missing object
+	Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, bucket, key string) error
 }
@@ -2,10 +2,15 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"MVP_checklist/internal/domain"
 )
 
 type FileSystemStorage struct {
@@ -21,17 +26,47 @@ func NewFileSystemStorage(basePath string) *FileSystemStorage {
 	}
 }
 
-func (s *FileSystemStorage) Upload(ctx context.Context, bucket, key string, data []byte) (string, error) {
+// path is the file of an object; keys may carry the /uploads/ prefix of
+// their URL.
+func (s *FileSystemStorage) path(key string) string {
+	return filepath.Join(s.basePath, strings.TrimPrefix(key, "/uploads/"))
+}
+
+func (s *FileSystemStorage) Upload(ctx context.Context, bucket, key string, r io.Reader, size int64, _ string) (string, error) {
 	// Игнорируем bucket для FS или используем как подпапку
-	fullPath := filepath.Join(s.basePath, key)
+	fullPath := s.path(key)
 
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
 		return "", fmt.Errorf("failed to create folder: %w", err)
 	}
 
-	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+	// Пишем во временный файл рядом и переименовываем, чтобы файл по ключу
+	// никогда не был записан наполовину
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
 
 	// Возвращаем ключ (относительный путь), который будет использоваться в GetURL
 	return key, nil
@@ -43,3 +78,42 @@ func (s *FileSystemStorage) GetURL(ctx context.Context, bucket, key string) (str
 	}
 	return "/uploads/" + key, nil
 }
+
+func (s *FileSystemStorage) Open(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("file %s: %w", key, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *FileSystemStorage) Stat(ctx context.Context, bucket, key string) (*domain.ObjectInfo, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("file %s: %w", key, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	contentType, _, err := detectContentType(key, "", f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return &domain.ObjectInfo{Size: stat.Size(), ContentType: contentType, ModTime: stat.ModTime()}, nil
+}
+
+func (s *FileSystemStorage) Delete(ctx context.Context, bucket, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
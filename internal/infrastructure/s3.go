@@ -3,13 +3,26 @@ package infrastructure
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
 	"time"
 
+	"MVP_checklist/internal/domain"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// s3PartSize is the size of the parts objects are streamed to S3 in; S3
+// requires at least 5 MB for every part but the last.
+const s3PartSize = 8 << 20
+
 type S3Storage struct {
 	client *s3.Client
 	bucket string
@@ -22,18 +35,133 @@ func NewS3Storage(client *s3.Client, bucket string) *S3Storage {
 	}
 }
 
-func (s *S3Storage) Upload(ctx context.Context, _, key string, data []byte) (string, error) {
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
+// Upload puts objects that fit in one part at once and streams larger ones
+// as a multipart upload, holding one part in memory at a time.
+func (s *S3Storage) Upload(ctx context.Context, _, key string, r io.Reader, size int64, contentType string) (string, error) {
+	contentType, r, err := detectContentType(key, contentType, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	buf := make([]byte, s3PartSize)
+	if size >= 0 && size < s3PartSize {
+		// The byte past size tells a reader longer than announced
+		buf = buf[:size+1]
+	}
+	n, done, err := readPart(r, buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if done || int64(len(buf)) == size+1 {
+		if size >= 0 && int64(n) != size {
+			return "", fmt.Errorf("failed to upload to s3: content does not match size %d", size)
+		}
+		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+			ContentType:   aws.String(contentType),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to upload to s3: %w", err)
+		}
+		return key, nil
+	}
+
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to upload to s3: %w", err)
+		return "", fmt.Errorf("failed to start s3 multipart upload: %w", err)
+	}
+	if err := s.uploadParts(ctx, key, created.UploadId, r, size, buf); err != nil {
+		// Parts of an aborted upload are not kept, nor billed
+		_, abortErr := s.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		if abortErr != nil {
+			log.Printf("Failed to abort s3 multipart upload of %s: %v", key, abortErr)
+		}
+		return "", err
 	}
 	return key, nil
 }
 
+// uploadParts uploads the full part in buf and the rest of r as the parts of
+// a multipart upload and completes it.
+func (s *S3Storage) uploadParts(ctx context.Context, key string, uploadID *string, r io.Reader, size int64, buf []byte) error {
+	var parts []types.CompletedPart
+	var total int64
+	n, done := len(buf), false
+	for number := int32(1); n > 0; number++ {
+		out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d to s3: %w", number, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(number)})
+		total += int64(n)
+		if done {
+			break
+		}
+		if n, done, err = readPart(r, buf); err != nil {
+			return fmt.Errorf("failed to read upload: %w", err)
+		}
+	}
+	if size >= 0 && total != size {
+		return fmt.Errorf("failed to upload to s3: content does not match size %d", size)
+	}
+
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete s3 multipart upload: %w", err)
+	}
+	return nil
+}
+
+// readPart fills buf from r; done reports that r ended.
+func readPart(r io.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, true, nil
+	}
+	return n, false, err
+}
+
+// detectContentType returns contentType if set, else the MIME type of the
+// key's extension, else the type sniffed from the head of r. The returned
+// reader yields all of r.
+func detectContentType(key, contentType string, r io.Reader) (string, io.Reader, error) {
+	if contentType != "" {
+		return contentType, r, nil
+	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t, r, nil
+	}
+	head := make([]byte, 512)
+	n, _, err := readPart(r, head)
+	if err != nil {
+		return "", nil, err
+	}
+	return http.DetectContentType(head[:n]), io.MultiReader(bytes.NewReader(head[:n]), r), nil
+}
+
 func (s *S3Storage) GetURL(ctx context.Context, _, key string) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
 	presignedUrl, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
@@ -46,3 +174,48 @@ func (s *S3Storage) GetURL(ctx context.Context, _, key string) (string, error) {
 	}
 	return presignedUrl.URL, nil
 }
+
+func (s *S3Storage) Open(ctx context.Context, _, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("s3 object %s: %w", key, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from s3: %w", err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, _, key string) (*domain.ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return nil, fmt.Errorf("s3 object %s: %w", key, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat s3 object: %w", err)
+	}
+	return &domain.ObjectInfo{
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ModTime:     aws.ToTime(out.LastModified),
+	}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, _, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from s3: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"MVP_checklist/internal/domain"
@@ -20,6 +21,13 @@ func NewPostgresRepository(db *pgxpool.Pool) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern builds an ILIKE pattern that matches s literally anywhere in a value.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
 func (r *PostgresRepository) CreateTemplate(ctx context.Context, t *domain.ChecklistTemplate) error {
 	query := `INSERT INTO checklist_templates (id, role, version, is_active) VALUES ($1, $2, $3, $4)`
 	_, err := r.db.Exec(ctx, query, t.ID, string(t.Role), t.Version, t.IsActive)
@@ -27,9 +35,9 @@ func (r *PostgresRepository) CreateTemplate(ctx context.Context, t *domain.Check
 }
 
 func (r *PostgresRepository) CreateQuestion(ctx context.Context, q *domain.Question) error {
-	query := `INSERT INTO questions (id, template_id, text, "order", min_photos, max_photos, is_required, reference_images) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(ctx, query, q.ID, q.TemplateID, q.Text, q.Order, q.MinPhotos, q.MaxPhotos, q.IsRequired, q.ReferenceImages)
+	query := `INSERT INTO questions (id, template_id, text, type, config, show_if, "order", min_photos, max_photos, is_required, reference_images) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.Exec(ctx, query, q.ID, q.TemplateID, q.Text, string(q.Type), q.Config, q.ShowIf, q.Order, q.MinPhotos, q.MaxPhotos, q.IsRequired, q.ReferenceImages)
 	return err
 }
 
@@ -61,7 +69,7 @@ func (r *PostgresRepository) GetTemplateByRole(ctx context.Context, role domain.
 	err := r.db.QueryRow(ctx, query, string(role)).Scan(&t.ID, &t.Role, &t.Version, &t.IsActive, &t.CreatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("template not found for role %s", role)
+			return nil, fmt.Errorf("template for role %s: %w", role, domain.ErrNotFound)
 		}
 		return nil, err
 	}
@@ -74,13 +82,16 @@ func (r *PostgresRepository) GetTemplateByID(ctx context.Context, id uuid.UUID)
 	var t domain.ChecklistTemplate
 	err := r.db.QueryRow(ctx, query, id).Scan(&t.ID, &t.Role, &t.Version, &t.IsActive, &t.CreatedAt)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("template %s: %w", id, domain.ErrNotFound)
+		}
 		return nil, err
 	}
 	return &t, nil
 }
 
 func (r *PostgresRepository) GetQuestionsByTemplateID(ctx context.Context, templateID uuid.UUID) ([]domain.Question, error) {
-	query := `SELECT id, template_id, text, "order", min_photos, max_photos, is_required, reference_images, created_at 
+	query := `SELECT id, template_id, text, type, config, show_if, "order", min_photos, max_photos, is_required, reference_images, created_at 
               FROM questions WHERE template_id = $1 ORDER BY "order" ASC`
 
 	rows, err := r.db.Query(ctx, query, templateID)
@@ -92,7 +103,7 @@ func (r *PostgresRepository) GetQuestionsByTemplateID(ctx context.Context, templ
 	var questions []domain.Question
 	for rows.Next() {
 		var q domain.Question
-		err := rows.Scan(&q.ID, &q.TemplateID, &q.Text, &q.Order, &q.MinPhotos, &q.MaxPhotos, &q.IsRequired, &q.ReferenceImages, &q.CreatedAt)
+		err := rows.Scan(&q.ID, &q.TemplateID, &q.Text, &q.Type, &q.Config, &q.ShowIf, &q.Order, &q.MinPhotos, &q.MaxPhotos, &q.IsRequired, &q.ReferenceImages, &q.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -101,44 +112,77 @@ func (r *PostgresRepository) GetQuestionsByTemplateID(ctx context.Context, templ
 	return questions, nil
 }
 
+// inspectionColumns is the select list read by scanInspection; queries alias inspections as "i".
+const inspectionColumns = `i.id, i.template_id, i.machine_id, i.machine_serial, i.inspector_id, i.inspector_name, i.status, COALESCE(i.verdict, ''), i.started_at, i.finished_at, i.stage_override_id, COALESCE(i.cancel_reason, ''), COALESCE(i.cancelled_by, ''), COALESCE(i.approved_by, ''), i.approved_at, COALESCE(i.signature_key, ''), COALESCE(i.signed_by, ''), i.signed_at`
+
+func scanInspection(row pgx.Row, i *domain.Inspection, extra ...any) error {
+	dest := []any{&i.ID, &i.TemplateID, &i.MachineID, &i.MachineSerial, &i.InspectorID, &i.InspectorName, &i.Status, &i.Verdict, &i.StartedAt, &i.FinishedAt, &i.StageOverrideID, &i.CancelReason, &i.CancelledBy, &i.ApprovedBy, &i.ApprovedAt, &i.SignatureKey, &i.SignedBy, &i.SignedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
 func (r *PostgresRepository) CreateInspection(ctx context.Context, inspection *domain.Inspection) error {
-	query := `INSERT INTO inspections (id, template_id, machine_serial, inspector_name, status, started_at) 
-              VALUES ($1, $2, $3, $4, $5, $6)`
+	query := `INSERT INTO inspections (id, template_id, machine_id, machine_serial, inspector_id, inspector_name, status, started_at, stage_override_id) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	_, err := r.db.Exec(ctx, query, inspection.ID, inspection.TemplateID, inspection.MachineSerial, inspection.InspectorName, string(inspection.Status), inspection.StartedAt)
+	_, err := r.db.Exec(ctx, query, inspection.ID, inspection.TemplateID, inspection.MachineID, inspection.MachineSerial, inspection.InspectorID, inspection.InspectorName, string(inspection.Status), inspection.StartedAt, inspection.StageOverrideID)
 	return err
 }
 
 func (r *PostgresRepository) GetInspectionByID(ctx context.Context, id uuid.UUID) (*domain.Inspection, error) {
-	query := `SELECT id, template_id, machine_serial, inspector_name, status, started_at, finished_at 
-              FROM inspections WHERE id = $1`
+	query := `SELECT ` + inspectionColumns + ` FROM inspections i WHERE i.id = $1`
 
 	var i domain.Inspection
-	err := r.db.QueryRow(ctx, query, id).Scan(&i.ID, &i.TemplateID, &i.MachineSerial, &i.InspectorName, &i.Status, &i.StartedAt, &i.FinishedAt)
+	err := scanInspection(r.db.QueryRow(ctx, query, id), &i)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("inspection %s: %w", id, domain.ErrNotFound)
+		}
 		return nil, err
 	}
 	return &i, nil
 }
 
-func (r *PostgresRepository) ListInspections(ctx context.Context, role *domain.Role, status *domain.InspectionStatus) ([]domain.Inspection, error) {
-	query := `SELECT i.id, i.template_id, i.machine_serial, i.inspector_name, i.status, i.started_at, i.finished_at 
+func (r *PostgresRepository) ListInspections(ctx context.Context, filter domain.InspectionFilter) ([]domain.Inspection, error) {
+	query := `SELECT ` + inspectionColumns + ` 
               FROM inspections i 
               JOIN checklist_templates t ON i.template_id = t.id 
               WHERE 1=1`
 	args := []interface{}{}
 	argIdx := 1
 
-	if role != nil {
+	if filter.Role != nil {
 		query += fmt.Sprintf(" AND t.role = $%d", argIdx)
-		args = append(args, string(*role))
+		args = append(args, string(*filter.Role))
+		argIdx++
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for n, s := range filter.Statuses {
+			statuses[n] = string(s)
+		}
+		query += fmt.Sprintf(" AND i.status = ANY($%d)", argIdx)
+		args = append(args, statuses)
+		argIdx++
+	}
+	if filter.InspectorID != nil {
+		query += fmt.Sprintf(" AND i.inspector_id = $%d", argIdx)
+		args = append(args, *filter.InspectorID)
+		argIdx++
+	}
+	if filter.MachineSerial != "" {
+		query += fmt.Sprintf(" AND i.machine_serial = $%d", argIdx)
+		args = append(args, filter.MachineSerial)
 		argIdx++
 	}
-	if status != nil {
-		query += fmt.Sprintf(" AND i.status = $%d", argIdx)
-		args = append(args, string(*status))
+	if filter.AwaitingReview {
+		query += fmt.Sprintf(" AND i.status = $%d AND i.approved_at IS NULL", argIdx)
+		args = append(args, string(domain.StatusCompleted))
 		argIdx++
 	}
+	if filter.WrongSubject {
+		query += ` AND EXISTS (SELECT 1 FROM inspection_answers ia JOIN answer_photos ap ON ap.answer_id = ia.id
+                               WHERE ia.inspection_id = i.id AND ap.wrong_subject)`
+	}
 	query += " ORDER BY i.started_at DESC"
 
 	rows, err := r.db.Query(ctx, query, args...)
@@ -150,7 +194,7 @@ func (r *PostgresRepository) ListInspections(ctx context.Context, role *domain.R
 	var inspections []domain.Inspection
 	for rows.Next() {
 		var i domain.Inspection
-		err := rows.Scan(&i.ID, &i.TemplateID, &i.MachineSerial, &i.InspectorName, &i.Status, &i.StartedAt, &i.FinishedAt)
+		err := scanInspection(rows, &i)
 		if err != nil {
 			return nil, err
 		}
@@ -160,8 +204,16 @@ func (r *PostgresRepository) ListInspections(ctx context.Context, role *domain.R
 }
 
 func (r *PostgresRepository) GetInspectionAnswers(ctx context.Context, inspectionID uuid.UUID) ([]domain.InspectionAnswer, error) {
-	query := `SELECT ia.id, ia.inspection_id, ia.question_id, ia.comment, ia.created_at, 
-              array_remove(array_agg(ap.file_url), NULL) as photos
+	query := `SELECT ia.id, ia.inspection_id, ia.question_id, COALESCE(ia.verdict, ''), ia.value, COALESCE(ia.comment, ''), ia.created_at, COALESCE(ia.updated_at, ia.created_at),
+              COALESCE(ia.rework_comment, ''), ia.rework_requested_at,
+              COALESCE(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+                  'key', ap.file_url, 'original_key', ap.original_key, 'thumbnail_key', ap.thumbnail_key, 'phash', ap.phash,
+                  'quality', CASE WHEN ap.sharpness IS NOT NULL THEN jsonb_build_object(
+                      'sharpness', ap.sharpness, 'brightness', ap.brightness, 'clipped', ap.clipped, 'issues', ap.quality_issues) END,
+                  'taken_at', ap.taken_at, 'device', ap.device, 'orientation', ap.orientation,
+                  'latitude', ap.latitude, 'longitude', ap.longitude, 'stale', ap.stale,
+                  'reference_similarity', ap.reference_similarity, 'wrong_subject', ap.wrong_subject
+              )) ORDER BY ap.position, ap.created_at) FILTER (WHERE ap.id IS NOT NULL), '[]') as photos
               FROM inspection_answers ia
               LEFT JOIN answer_photos ap ON ia.id = ap.answer_id
               WHERE ia.inspection_id = $1
@@ -176,7 +228,7 @@ func (r *PostgresRepository) GetInspectionAnswers(ctx context.Context, inspectio
 	var answers []domain.InspectionAnswer
 	for rows.Next() {
 		var a domain.InspectionAnswer
-		err := rows.Scan(&a.ID, &a.InspectionID, &a.QuestionID, &a.Comment, &a.CreatedAt, &a.Photos)
+		err := rows.Scan(&a.ID, &a.InspectionID, &a.QuestionID, &a.Verdict, &a.Value, &a.Comment, &a.CreatedAt, &a.UpdatedAt, &a.ReworkComment, &a.ReworkRequestedAt, &a.Photos)
 		if err != nil {
 			return nil, err
 		}
@@ -185,30 +237,58 @@ func (r *PostgresRepository) GetInspectionAnswers(ctx context.Context, inspectio
 	return answers, nil
 }
 
-func (r *PostgresRepository) SaveAnswer(ctx context.Context, answer *domain.InspectionAnswer) error {
+func (r *PostgresRepository) SaveAnswer(ctx context.Context, answer *domain.InspectionAnswer, cleanupAfter time.Time) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
-	queryAnswer := `INSERT INTO inspection_answers (id, inspection_id, question_id, comment) 
-                    VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET comment = $4`
+	// An inspection has one answer per question; saving again replaces it
+	queryAnswer := `INSERT INTO inspection_answers (id, inspection_id, question_id, verdict, value, comment, created_at, updated_at)
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+                    ON CONFLICT (inspection_id, question_id) DO UPDATE SET verdict = $4, value = $5, comment = $6, updated_at = $7
+                    RETURNING id, created_at`
 
-	_, err = tx.Exec(ctx, queryAnswer, answer.ID, answer.InspectionID, answer.QuestionID, answer.Comment)
+	err = tx.QueryRow(ctx, queryAnswer, answer.ID, answer.InspectionID, answer.QuestionID, string(answer.Verdict), answer.Value, answer.Comment, answer.UpdatedAt).
+		Scan(&answer.ID, &answer.CreatedAt)
 	if err != nil {
 		return err
 	}
 
-	// Delete old photos for this answer if we are updating (simple approach for MVP)
-	_, err = tx.Exec(ctx, "DELETE FROM answer_photos WHERE answer_id = $1", answer.ID)
+	// Replace the photo list of the answer; files of photos removed or
+	// replaced go to cleanup along with their originals and thumbnails
+	keep := make([]string, 0, 3*len(answer.Photos)) // not NULL, which ANY would never match
+	for _, photo := range answer.Photos {
+		keep = append(keep, photo.Key, photo.OriginalKey, photo.ThumbnailKey)
+	}
+	queryDelete := `WITH dropped AS (
+                        DELETE FROM answer_photos WHERE answer_id = $1 RETURNING file_url, original_key, thumbnail_key
+                    )
+                    INSERT INTO storage_cleanup (key, delete_after)
+                    SELECT k.key, $3 FROM dropped
+                    CROSS JOIN LATERAL unnest(ARRAY[dropped.file_url, dropped.original_key, dropped.thumbnail_key]) AS k(key)
+                    WHERE k.key IS NOT NULL AND NOT (k.key = ANY($2))
+                    ON CONFLICT (key) DO NOTHING`
+	_, err = tx.Exec(ctx, queryDelete, answer.ID, keep, cleanupAfter)
 	if err != nil {
 		return err
 	}
 
-	queryPhoto := `INSERT INTO answer_photos (answer_id, file_url) VALUES ($1, $2)`
-	for _, photo := range answer.Photos {
-		_, err = tx.Exec(ctx, queryPhoto, answer.ID, photo)
+	queryPhoto := `INSERT INTO answer_photos (answer_id, file_url, original_key, thumbnail_key, phash, position, taken_at, device, orientation, latitude, longitude, stale,
+                                             sharpness, brightness, clipped, quality_issues, reference_similarity, wrong_subject)
+                   VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, 0), $6, $7, NULLIF($8, ''), NULLIF($9, 0), $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+	for i, photo := range answer.Photos {
+		var sharpness, brightness, clipped *float64
+		var issues []string
+		if q := photo.Quality; q != nil {
+			sharpness, brightness, clipped = &q.Sharpness, &q.Brightness, &q.Clipped
+			for _, issue := range q.Issues {
+				issues = append(issues, string(issue))
+			}
+		}
+		_, err = tx.Exec(ctx, queryPhoto, answer.ID, photo.Key, photo.OriginalKey, photo.ThumbnailKey, photo.PerceptualHash, i, photo.TakenAt, photo.Device, photo.Orientation, photo.Latitude, photo.Longitude, photo.Stale,
+			sharpness, brightness, clipped, issues, photo.ReferenceSimilarity, photo.WrongSubject)
 		if err != nil {
 			return err
 		}
@@ -217,41 +297,431 @@ func (r *PostgresRepository) SaveAnswer(ctx context.Context, answer *domain.Insp
 	return tx.Commit(ctx)
 }
 
-func (r *PostgresRepository) CompleteInspection(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE inspections SET status = $1, finished_at = $2 WHERE id = $3`
-	_, err := r.db.Exec(ctx, query, string(domain.StatusCompleted), time.Now(), id)
-	return err
+func (r *PostgresRepository) CompleteInspection(ctx context.Context, id uuid.UUID, verdict domain.Verdict, signature domain.Signature) error {
+	// Cancelling or abandoning may have closed the inspection meanwhile
+	query := `UPDATE inspections SET status = $1, verdict = $2, finished_at = $3, signature_key = $4, signed_by = $5, signed_at = $6 WHERE id = $7 AND status = ANY($8)`
+	open := []string{string(domain.StatusInProgress), string(domain.StatusReturned)}
+	tag, err := r.db.Exec(ctx, query, string(domain.StatusCompleted), string(verdict), time.Now(), signature.Key, signature.SignedBy, signature.SignedAt, id, open)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
 }
 
-func (r *PostgresRepository) DeleteTemplateByRole(ctx context.Context, role domain.Role) error {
+func (r *PostgresRepository) CancelInspection(ctx context.Context, id uuid.UUID, reason, cancelledBy string) error {
+	query := `UPDATE inspections SET status = $1, cancel_reason = $2, cancelled_by = $3, finished_at = $4 WHERE id = $5 AND status = ANY($6)`
+	open := []string{string(domain.StatusInProgress), string(domain.StatusReturned)}
+	tag, err := r.db.Exec(ctx, query, string(domain.StatusCancelled), reason, cancelledBy, time.Now(), id, open)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ReviewInspection(ctx context.Context, review *domain.InspectionReview) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
-	// Get all template IDs for this role
-	rows, err := tx.Query(ctx, "SELECT id FROM checklist_templates WHERE role = $1", string(role))
+	// A returned inspection gets its verdict again when it is completed
+	query := `UPDATE inspections SET status = $3, verdict = NULL, finished_at = NULL, signature_key = NULL, signed_by = NULL, signed_at = NULL`
+	args := []any{review.InspectionID, string(domain.StatusCompleted), string(domain.StatusReturned)}
+	if review.Decision == domain.ReviewApproved {
+		query = `UPDATE inspections SET approved_by = $3, approved_at = $4`
+		args = []any{review.InspectionID, string(domain.StatusCompleted), review.ReviewerName, review.CreatedAt}
+	}
+	tag, err := tx.Exec(ctx, query+` WHERE id = $1 AND status = $2 AND approved_at IS NULL`, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	// Remarks of the previous review no longer apply
+	_, err = tx.Exec(ctx, `UPDATE inspection_answers SET rework_comment = NULL, rework_requested_at = NULL WHERE inspection_id = $1`, review.InspectionID)
+	if err != nil {
+		return err
+	}
+	queryRework := `UPDATE inspection_answers SET rework_comment = $3, rework_requested_at = $4 WHERE inspection_id = $1 AND question_id = $2`
+	for _, rework := range review.Rework {
+		_, err = tx.Exec(ctx, queryRework, review.InspectionID, rework.QuestionID, rework.Comment, review.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	queryReview := `INSERT INTO inspection_reviews (id, inspection_id, decision, reviewer_id, reviewer_name, comment, rework, created_at)
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	rework := review.Rework
+	if rework == nil {
+		rework = []domain.ReworkRequest{}
+	}
+	_, err = tx.Exec(ctx, queryReview, review.ID, review.InspectionID, string(review.Decision), review.ReviewerID, review.ReviewerName, review.Comment, rework, review.CreatedAt)
 	if err != nil {
 		return err
 	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) ListInspectionReviews(ctx context.Context, inspectionID uuid.UUID) ([]domain.InspectionReview, error) {
+	query := `SELECT id, inspection_id, decision, reviewer_id, reviewer_name, comment, rework, created_at
+              FROM inspection_reviews WHERE inspection_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(ctx, query, inspectionID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var templateIDs []uuid.UUID
+	var reviews []domain.InspectionReview
+	for rows.Next() {
+		var rv domain.InspectionReview
+		if err := rows.Scan(&rv.ID, &rv.InspectionID, &rv.Decision, &rv.ReviewerID, &rv.ReviewerName, &rv.Comment, &rv.Rework, &rv.CreatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, rv)
+	}
+	return reviews, nil
+}
+
+// sealLockKey is the advisory lock that serializes appends to the seal chain.
+const sealLockKey = 0x5ea1
+
+func (r *PostgresRepository) SealInspection(ctx context.Context, inspectionID uuid.UUID, contentHash string) (*domain.InspectionSeal, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", sealLockKey); err != nil {
+		return nil, err
+	}
+
+	seal := domain.InspectionSeal{InspectionID: inspectionID, ContentHash: contentHash}
+	err = tx.QueryRow(ctx, "SELECT sequence, hash FROM inspection_seals ORDER BY sequence DESC LIMIT 1").Scan(&seal.Sequence, &seal.PrevHash)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	seal.Sequence++
+	seal.Hash = domain.ChainHash(seal.Sequence, inspectionID, contentHash, seal.PrevHash)
+
+	query := `INSERT INTO inspection_seals (sequence, inspection_id, content_hash, prev_hash, hash)
+              VALUES ($1, $2, $3, $4, $5) RETURNING created_at`
+	err = tx.QueryRow(ctx, query, seal.Sequence, inspectionID, contentHash, seal.PrevHash, seal.Hash).Scan(&seal.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &seal, nil
+}
+
+const sealColumns = `sequence, inspection_id, content_hash, prev_hash, hash, created_at`
+
+func (r *PostgresRepository) GetInspectionSeal(ctx context.Context, inspectionID uuid.UUID) (*domain.InspectionSeal, error) {
+	query := `SELECT ` + sealColumns + ` FROM inspection_seals WHERE inspection_id = $1 ORDER BY sequence DESC LIMIT 1`
+
+	var s domain.InspectionSeal
+	err := r.db.QueryRow(ctx, query, inspectionID).Scan(&s.Sequence, &s.InspectionID, &s.ContentHash, &s.PrevHash, &s.Hash, &s.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresRepository) ListInspectionSeals(ctx context.Context) ([]domain.InspectionSeal, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+sealColumns+` FROM inspection_seals ORDER BY sequence`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seals []domain.InspectionSeal
+	for rows.Next() {
+		var s domain.InspectionSeal
+		if err := rows.Scan(&s.Sequence, &s.InspectionID, &s.ContentHash, &s.PrevHash, &s.Hash, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		seals = append(seals, s)
+	}
+	return seals, nil
+}
+
+func (r *PostgresRepository) AbandonInspections(ctx context.Context, idleSince, cleanupAfter time.Time) ([]uuid.UUID, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The last activity is the latest answer, or the start for an inspection without answers
+	query := `UPDATE inspections i SET status = $1, finished_at = $2
+              WHERE i.status = $3
+                AND COALESCE((SELECT max(COALESCE(ia.updated_at, ia.created_at)) FROM inspection_answers ia WHERE ia.inspection_id = i.id), i.started_at) < $4
+              RETURNING i.id`
+	rows, err := tx.Query(ctx, query, string(domain.StatusAbandoned), time.Now(), string(domain.StatusInProgress), idleSince)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
 	for rows.Next() {
 		var id uuid.UUID
-		if err := rows.Scan(&id); err == nil {
-			templateIDs = append(templateIDs, id)
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Originals and thumbnails go along with the photos
+	queryCleanup := `INSERT INTO storage_cleanup (key, delete_after)
+                     SELECT k.key, $2 FROM answer_photos ap
+                     JOIN inspection_answers ia ON ia.id = ap.answer_id
+                     CROSS JOIN LATERAL unnest(ARRAY[ap.file_url, ap.original_key, ap.thumbnail_key]) AS k(key)
+                     WHERE ia.inspection_id = ANY($1) AND k.key IS NOT NULL
+                     ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.Exec(ctx, queryCleanup, ids, cleanupAfter); err != nil {
+		return nil, err
+	}
+
+	return ids, tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) CreatePhotoUpload(ctx context.Context, upload *domain.PhotoUpload) error {
+	query := `INSERT INTO photo_uploads (id, inspection_id, size, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)`
+	_, err := r.db.Exec(ctx, query, upload.ID, upload.InspectionID, upload.Size, upload.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) GetPhotoUpload(ctx context.Context, id uuid.UUID) (*domain.PhotoUpload, error) {
+	query := `SELECT id, inspection_id, size, received, created_at, updated_at FROM photo_uploads WHERE id = $1`
+	var u domain.PhotoUpload
+	err := r.db.QueryRow(ctx, query, id).Scan(&u.ID, &u.InspectionID, &u.Size, &u.Offset, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("upload %s: %w", id, domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *PostgresRepository) AppendPhotoUpload(ctx context.Context, id uuid.UUID, chunk domain.PhotoUploadChunk) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The offset check makes a chunk retried after a lost response a no-op
+	query := `UPDATE photo_uploads SET received = received + $3, updated_at = $4
+              WHERE id = $1 AND received = $2 AND received + $3 <= size
+              RETURNING received`
+	var newOffset int64
+	err = tx.QueryRow(ctx, query, id, chunk.Offset, chunk.Size, time.Now()).Scan(&newOffset)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, fmt.Errorf("upload %s at offset %d: %w", id, chunk.Offset, domain.ErrNotFound)
+		}
+		return 0, err
+	}
+
+	queryChunk := `INSERT INTO photo_upload_chunks (upload_id, start_offset, size, key) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, queryChunk, id, chunk.Offset, chunk.Size, chunk.Key); err != nil {
+		return 0, err
+	}
+	return newOffset, tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) ListPhotoUploadChunks(ctx context.Context, id uuid.UUID) ([]domain.PhotoUploadChunk, error) {
+	query := `SELECT start_offset, size, key FROM photo_upload_chunks WHERE upload_id = $1 ORDER BY start_offset`
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []domain.PhotoUploadChunk
+	for rows.Next() {
+		var c domain.PhotoUploadChunk
+		if err := rows.Scan(&c.Offset, &c.Size, &c.Key); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+func (r *PostgresRepository) DeletePhotoUploads(ctx context.Context, ids []uuid.UUID) error {
+	_, err := r.deletePhotoUploads(ctx, `id = ANY($1)`, ids)
+	return err
+}
+
+func (r *PostgresRepository) DeleteStalePhotoUploads(ctx context.Context, before time.Time) (int64, error) {
+	return r.deletePhotoUploads(ctx, `updated_at < $1`, before)
+}
+
+// deletePhotoUploads deletes the uploads matching where, with its single
+// argument, and schedules their chunks for deletion from storage.
+func (r *PostgresRepository) deletePhotoUploads(ctx context.Context, where string, arg any) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	queryCleanup := `INSERT INTO storage_cleanup (key, delete_after)
+                     SELECT c.key, $2 FROM photo_upload_chunks c
+                     JOIN photo_uploads u ON u.id = c.upload_id
+                     WHERE u.` + where + `
+                     ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.Exec(ctx, queryCleanup, arg, time.Now()); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM photo_uploads WHERE `+where, arg)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+func (r *PostgresRepository) ListDueStorageCleanup(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	query := `SELECT key FROM storage_cleanup WHERE deleted_at IS NULL AND delete_after <= $1 ORDER BY delete_after LIMIT $2`
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
 		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *PostgresRepository) MarkStorageCleanedUp(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, `UPDATE storage_cleanup SET deleted_at = $1 WHERE key = $2`, time.Now(), key)
+	return err
+}
+
+func (r *PostgresRepository) PostponeStorageCleanup(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE storage_cleanup SET delete_after = $1 WHERE key = $2 AND deleted_at IS NULL`, until, key)
+	return err
+}
+
+func (r *PostgresRepository) FindSimilarPhotos(ctx context.Context, hash int64, excludeSerial string) ([]domain.HashedPhoto, error) {
+	query := `SELECT ap.file_url, COALESCE(ap.thumbnail_key, ''), ap.phash, i.id, i.machine_serial
+              FROM answer_photos ap
+              JOIN inspection_answers ia ON ia.id = ap.answer_id
+              JOIN inspections i ON i.id = ia.inspection_id
+              WHERE i.machine_serial <> $2 AND (
+                  ((ap.phash >> 48) & 65535) = (($1::bigint >> 48) & 65535) OR
+                  ((ap.phash >> 32) & 65535) = (($1::bigint >> 32) & 65535) OR
+                  ((ap.phash >> 16) & 65535) = (($1::bigint >> 16) & 65535) OR
+                  (ap.phash & 65535) = ($1::bigint & 65535))`
+	rows, err := r.db.Query(ctx, query, hash, excludeSerial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []domain.HashedPhoto
+	for rows.Next() {
+		var p domain.HashedPhoto
+		if err := rows.Scan(&p.Key, &p.ThumbnailKey, &p.Hash, &p.InspectionID, &p.MachineSerial); err != nil {
+			return nil, err
+		}
+		photos = append(photos, p)
+	}
+	return photos, nil
+}
+
+func (r *PostgresRepository) CreateDuplicatePhoto(ctx context.Context, flag *domain.DuplicatePhoto) error {
+	query := `INSERT INTO photo_duplicates (id, photo_key, photo_thumbnail_key, inspection_id, match_key, match_thumbnail_key, match_inspection_id, distance, created_at)
+              VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7, $8, $9)
+              ON CONFLICT (photo_key, match_key) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, flag.ID, flag.Photo.Key, flag.Photo.ThumbnailKey, flag.Photo.InspectionID,
+		flag.Match.Key, flag.Match.ThumbnailKey, flag.Match.InspectionID, flag.Distance, flag.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) ListDuplicatePhotos(ctx context.Context, inspectionID *uuid.UUID) ([]domain.DuplicatePhoto, error) {
+	query := `SELECT d.id, d.photo_key, COALESCE(d.photo_thumbnail_key, ''), d.inspection_id, pi.machine_serial,
+                     d.match_key, COALESCE(d.match_thumbnail_key, ''), d.match_inspection_id, mi.machine_serial,
+                     d.distance, d.created_at
+              FROM photo_duplicates d
+              JOIN inspections pi ON pi.id = d.inspection_id
+              JOIN inspections mi ON mi.id = d.match_inspection_id`
+	args := []interface{}{}
+	if inspectionID != nil {
+		query += ` WHERE d.inspection_id = $1 OR d.match_inspection_id = $1`
+		args = append(args, *inspectionID)
+	}
+	query += ` ORDER BY d.created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []domain.DuplicatePhoto
+	for rows.Next() {
+		var d domain.DuplicatePhoto
+		err := rows.Scan(&d.ID, &d.Photo.Key, &d.Photo.ThumbnailKey, &d.Photo.InspectionID, &d.Photo.MachineSerial,
+			&d.Match.Key, &d.Match.ThumbnailKey, &d.Match.InspectionID, &d.Match.MachineSerial,
+			&d.Distance, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, d)
+	}
+	return flags, nil
+}
+
+// DeleteTemplateByRole deletes every version of the role's template with its
+// questions. It fails if inspections reference the template.
+func (r *PostgresRepository) DeleteTemplateByRole(ctx context.Context, role domain.Role) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	for _, templateID := range templateIDs {
-		// Delete related records (simple approach for seeding)
-		_, _ = tx.Exec(ctx, "DELETE FROM answer_photos WHERE answer_id IN (SELECT id FROM inspection_answers WHERE question_id IN (SELECT id FROM questions WHERE template_id = $1))", templateID)
-		_, _ = tx.Exec(ctx, "DELETE FROM inspection_answers WHERE question_id IN (SELECT id FROM questions WHERE template_id = $1)", templateID)
-		_, _ = tx.Exec(ctx, "DELETE FROM inspections WHERE template_id = $1", templateID)
-		_, _ = tx.Exec(ctx, "DELETE FROM questions WHERE template_id = $1", templateID)
-		_, _ = tx.Exec(ctx, "DELETE FROM checklist_templates WHERE id = $1", templateID)
+	_, err = tx.Exec(ctx, "DELETE FROM questions WHERE template_id IN (SELECT id FROM checklist_templates WHERE role = $1)", string(role))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM checklist_templates WHERE role = $1", string(role))
+	if err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
@@ -262,3 +732,416 @@ func (r *PostgresRepository) DeactivateTemplatesByRole(ctx context.Context, role
 	_, err := r.db.Exec(ctx, query, string(role))
 	return err
 }
+
+func (r *PostgresRepository) ListTemplatesByRole(ctx context.Context, role domain.Role) ([]domain.ChecklistTemplate, error) {
+	query := `SELECT id, role, version, is_active, created_at FROM checklist_templates WHERE role = $1 ORDER BY version DESC`
+	rows, err := r.db.Query(ctx, query, string(role))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []domain.ChecklistTemplate
+	for rows.Next() {
+		var t domain.ChecklistTemplate
+		if err := rows.Scan(&t.ID, &t.Role, &t.Version, &t.IsActive, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func (r *PostgresRepository) ActivateTemplate(ctx context.Context, role domain.Role, id uuid.UUID) error {
+	query := `UPDATE checklist_templates SET is_active = (id = $2)
+              WHERE role = $1 AND EXISTS (SELECT 1 FROM checklist_templates WHERE id = $2 AND role = $1)`
+	tag, err := r.db.Exec(ctx, query, string(role), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("template %s: %w", id, domain.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) EnsureMachine(ctx context.Context, serial string) (*domain.Machine, error) {
+	// The no-op update makes RETURNING yield the existing row on conflict
+	query := `INSERT INTO machines (id, serial) VALUES ($1, $2)
+              ON CONFLICT (serial) DO UPDATE SET serial = EXCLUDED.serial
+              RETURNING id, serial, model, production_order, created_at, shipped_at`
+
+	var m domain.Machine
+	err := r.db.QueryRow(ctx, query, uuid.New(), serial).Scan(&m.ID, &m.Serial, &m.Model, &m.ProductionOrder, &m.CreatedAt, &m.ShippedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *PostgresRepository) GetMachineBySerial(ctx context.Context, serial string) (*domain.Machine, error) {
+	query := `SELECT id, serial, model, production_order, created_at, shipped_at FROM machines WHERE serial = $1`
+
+	var m domain.Machine
+	err := r.db.QueryRow(ctx, query, serial).Scan(&m.ID, &m.Serial, &m.Model, &m.ProductionOrder, &m.CreatedAt, &m.ShippedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("machine %s: %w", serial, domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *PostgresRepository) UpdateMachine(ctx context.Context, m *domain.Machine) error {
+	query := `UPDATE machines SET model = $1, production_order = $2, shipped_at = $3 WHERE id = $4`
+	_, err := r.db.Exec(ctx, query, m.Model, m.ProductionOrder, m.ShippedAt, m.ID)
+	return err
+}
+
+func (r *PostgresRepository) ListMachines(ctx context.Context, search string) ([]domain.MachineSummary, error) {
+	query := `SELECT m.id, m.serial, m.model, m.production_order, m.created_at, m.shipped_at,
+              COUNT(i.id), MAX(i.started_at)
+              FROM machines m
+              LEFT JOIN inspections i ON i.machine_id = m.id
+              WHERE $1 = '' OR m.serial ILIKE $2 OR m.production_order ILIKE $2
+              GROUP BY m.id
+              ORDER BY MAX(i.started_at) DESC NULLS LAST, m.serial`
+
+	rows, err := r.db.Query(ctx, query, search, containsPattern(search))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var machines []domain.MachineSummary
+	for rows.Next() {
+		var s domain.MachineSummary
+		m := &s.Machine
+		err := rows.Scan(&m.ID, &m.Serial, &m.Model, &m.ProductionOrder, &m.CreatedAt, &m.ShippedAt, &s.InspectionCount, &s.LastInspectionAt)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, s)
+	}
+	return machines, nil
+}
+
+func (r *PostgresRepository) ListMachineInspections(ctx context.Context, machineID uuid.UUID) ([]domain.MachineInspection, error) {
+	query := `SELECT ` + inspectionColumns + `, t.role
+              FROM inspections i
+              JOIN checklist_templates t ON i.template_id = t.id
+              WHERE i.machine_id = $1
+              ORDER BY i.started_at ASC`
+
+	rows, err := r.db.Query(ctx, query, machineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []domain.MachineInspection
+	for rows.Next() {
+		var mi domain.MachineInspection
+		err := scanInspection(rows, &mi.Inspection, &mi.Role)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, mi)
+	}
+	return history, nil
+}
+
+func (r *PostgresRepository) CreateStageOverride(ctx context.Context, o *domain.StageOverride) error {
+	query := `INSERT INTO stage_overrides (id, machine_id, role, reason, granted_by) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, query, o.ID, o.MachineID, string(o.Role), o.Reason, o.GrantedBy)
+	return err
+}
+
+func (r *PostgresRepository) GetUnusedStageOverride(ctx context.Context, machineID uuid.UUID, role domain.Role) (*domain.StageOverride, error) {
+	query := `SELECT o.id, o.machine_id, o.role, o.reason, o.granted_by, o.created_at
+              FROM stage_overrides o
+              WHERE o.machine_id = $1 AND o.role = $2
+                AND NOT EXISTS (SELECT 1 FROM inspections i WHERE i.stage_override_id = o.id)
+              ORDER BY o.created_at ASC LIMIT 1`
+
+	var o domain.StageOverride
+	err := r.db.QueryRow(ctx, query, machineID, string(role)).Scan(&o.ID, &o.MachineID, &o.Role, &o.Reason, &o.GrantedBy, &o.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("stage override for %s: %w", role, domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *PostgresRepository) ListStageOverrides(ctx context.Context, machineID uuid.UUID) ([]domain.StageOverride, error) {
+	query := `SELECT o.id, o.machine_id, o.role, o.reason, o.granted_by, o.created_at, i.id
+              FROM stage_overrides o
+              LEFT JOIN inspections i ON i.stage_override_id = o.id
+              WHERE o.machine_id = $1
+              ORDER BY o.created_at ASC`
+
+	rows, err := r.db.Query(ctx, query, machineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []domain.StageOverride
+	for rows.Next() {
+		var o domain.StageOverride
+		err := rows.Scan(&o.ID, &o.MachineID, &o.Role, &o.Reason, &o.GrantedBy, &o.CreatedAt, &o.InspectionID)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+func rolesToStrings(roles []domain.Role) []string {
+	result := make([]string, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, string(role))
+	}
+	return result
+}
+
+func stringsToRoles(values []string) []domain.Role {
+	roles := make([]domain.Role, 0, len(values))
+	for _, v := range values {
+		roles = append(roles, domain.Role(v))
+	}
+	return roles
+}
+
+func (r *PostgresRepository) CreateInspector(ctx context.Context, i *domain.Inspector) error {
+	query := `INSERT INTO inspectors (id, name, pin_hash, roles, is_active) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, query, i.ID, i.Name, i.PINHash, rolesToStrings(i.Roles), i.IsActive)
+	return err
+}
+
+func (r *PostgresRepository) UpdateInspector(ctx context.Context, i *domain.Inspector) error {
+	query := `UPDATE inspectors SET name = $1, pin_hash = $2, roles = $3, is_active = $4 WHERE id = $5`
+	_, err := r.db.Exec(ctx, query, i.Name, i.PINHash, rolesToStrings(i.Roles), i.IsActive, i.ID)
+	return err
+}
+
+func (r *PostgresRepository) getInspector(ctx context.Context, where string, arg any) (*domain.Inspector, error) {
+	query := `SELECT id, name, pin_hash, roles, is_active, created_at FROM inspectors WHERE ` + where
+
+	var i domain.Inspector
+	var roles []string
+	err := r.db.QueryRow(ctx, query, arg).Scan(&i.ID, &i.Name, &i.PINHash, &roles, &i.IsActive, &i.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("inspector: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	i.Roles = stringsToRoles(roles)
+	return &i, nil
+}
+
+func (r *PostgresRepository) GetInspectorByID(ctx context.Context, id uuid.UUID) (*domain.Inspector, error) {
+	return r.getInspector(ctx, "id = $1", id)
+}
+
+func (r *PostgresRepository) GetInspectorByName(ctx context.Context, name string) (*domain.Inspector, error) {
+	return r.getInspector(ctx, "LOWER(name) = LOWER($1)", name)
+}
+
+func (r *PostgresRepository) ListInspectors(ctx context.Context) ([]domain.Inspector, error) {
+	query := `SELECT id, name, pin_hash, roles, is_active, created_at FROM inspectors ORDER BY is_active DESC, name`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inspectors []domain.Inspector
+	for rows.Next() {
+		var i domain.Inspector
+		var roles []string
+		err := rows.Scan(&i.ID, &i.Name, &i.PINHash, &roles, &i.IsActive, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		i.Roles = stringsToRoles(roles)
+		inspectors = append(inspectors, i)
+	}
+	return inspectors, nil
+}
+
+func (r *PostgresRepository) CreateAdminUser(ctx context.Context, u *domain.AdminUser) error {
+	query := `INSERT INTO admin_users (id, login, name, password_hash, role, is_active) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, u.ID, u.Login, u.Name, u.PasswordHash, string(u.Role), u.IsActive)
+	return err
+}
+
+func (r *PostgresRepository) UpdateAdminUser(ctx context.Context, u *domain.AdminUser) error {
+	query := `UPDATE admin_users SET name = $1, password_hash = $2, role = $3, is_active = $4 WHERE id = $5`
+	_, err := r.db.Exec(ctx, query, u.Name, u.PasswordHash, string(u.Role), u.IsActive, u.ID)
+	return err
+}
+
+func (r *PostgresRepository) getAdminUser(ctx context.Context, where string, arg any) (*domain.AdminUser, error) {
+	query := `SELECT id, login, name, password_hash, role, is_active, created_at FROM admin_users WHERE ` + where
+
+	var u domain.AdminUser
+	err := r.db.QueryRow(ctx, query, arg).Scan(&u.ID, &u.Login, &u.Name, &u.PasswordHash, &u.Role, &u.IsActive, &u.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("admin user: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *PostgresRepository) GetAdminUserByID(ctx context.Context, id uuid.UUID) (*domain.AdminUser, error) {
+	return r.getAdminUser(ctx, "id = $1", id)
+}
+
+func (r *PostgresRepository) GetAdminUserByLogin(ctx context.Context, login string) (*domain.AdminUser, error) {
+	return r.getAdminUser(ctx, "LOWER(login) = LOWER($1)", login)
+}
+
+func (r *PostgresRepository) ListAdminUsers(ctx context.Context) ([]domain.AdminUser, error) {
+	query := `SELECT id, login, name, password_hash, role, is_active, created_at FROM admin_users ORDER BY is_active DESC, login`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []domain.AdminUser
+	for rows.Next() {
+		var u domain.AdminUser
+		err := rows.Scan(&u.ID, &u.Login, &u.Name, &u.PasswordHash, &u.Role, &u.IsActive, &u.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (r *PostgresRepository) CreateAuditEntry(ctx context.Context, e *domain.AuditEntry) error {
+	query := `INSERT INTO audit_log (id, actor_kind, actor_id, actor_name, action, entity_type, entity_id, before, after, ip, user_agent, request, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := r.db.Exec(ctx, query, e.ID, string(e.Actor.Kind), e.Actor.ID, e.Actor.Name, string(e.Action), e.EntityType, e.EntityID,
+		jsonOrNull(e.Before), jsonOrNull(e.After), e.Actor.IP, e.Actor.UserAgent, e.Actor.Request, e.CreatedAt)
+	return err
+}
+
+// jsonOrNull passes an empty snapshot as SQL NULL.
+func jsonOrNull(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+func (r *PostgresRepository) ListAuditEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	query := `SELECT id, actor_kind, actor_id, actor_name, action, entity_type, entity_id, COALESCE(before::text, ''), COALESCE(after::text, ''), ip, user_agent, request, created_at
+              FROM audit_log WHERE 1=1`
+	args := []interface{}{}
+	argIdx := 1
+
+	if filter.Action != "" {
+		query += fmt.Sprintf(" AND action = $%d", argIdx)
+		args = append(args, string(filter.Action))
+		argIdx++
+	}
+	if filter.EntityType != "" {
+		query += fmt.Sprintf(" AND entity_type = $%d", argIdx)
+		args = append(args, filter.EntityType)
+		argIdx++
+	}
+	if filter.EntityID != "" {
+		query += fmt.Sprintf(" AND entity_id = $%d", argIdx)
+		args = append(args, filter.EntityID)
+		argIdx++
+	}
+	if filter.Actor != "" {
+		query += fmt.Sprintf(" AND actor_name ILIKE $%d", argIdx)
+		args = append(args, containsPattern(filter.Actor))
+		argIdx++
+	}
+	if filter.Since != nil {
+		query += fmt.Sprintf(" AND created_at >= $%d", argIdx)
+		args = append(args, *filter.Since)
+		argIdx++
+	}
+	if filter.Until != nil {
+		query += fmt.Sprintf(" AND created_at < $%d", argIdx)
+		args = append(args, *filter.Until)
+		argIdx++
+	}
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argIdx)
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		var e domain.AuditEntry
+		var before, after string
+		err := rows.Scan(&e.ID, &e.Actor.Kind, &e.Actor.ID, &e.Actor.Name, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.Actor.IP, &e.Actor.UserAgent, &e.Actor.Request, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if before != "" {
+			e.Before = []byte(before)
+		}
+		if after != "" {
+			e.After = []byte(after)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (r *PostgresRepository) CreateSession(ctx context.Context, s *domain.Session) error {
+	query := `INSERT INTO sessions (token_hash, kind, subject_id, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.Exec(ctx, query, s.TokenHash, string(s.Kind), s.SubjectID, s.ExpiresAt)
+	return err
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, tokenHash string) (*domain.Session, error) {
+	query := `SELECT token_hash, kind, subject_id, created_at, expires_at FROM sessions
+              WHERE token_hash = $1 AND expires_at > NOW()`
+
+	var s domain.Session
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&s.TokenHash, &s.Kind, &s.SubjectID, &s.CreatedAt, &s.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("session: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	return err
+}
+
+func (r *PostgresRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM sessions WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
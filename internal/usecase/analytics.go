@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"MVP_checklist/internal/domain"
 	"github.com/google/uuid"
@@ -20,8 +23,8 @@ func NewAnalyticsUseCase(repo domain.ChecklistRepository, storage domain.FileSto
 	return &AnalyticsUseCase{repo: repo, storage: storage}
 }
 
-func (u *AnalyticsUseCase) ListInspections(ctx context.Context, role *domain.Role, status *domain.InspectionStatus) ([]domain.Inspection, error) {
-	return u.repo.ListInspections(ctx, role, status)
+func (u *AnalyticsUseCase) ListInspections(ctx context.Context, filter domain.InspectionFilter) ([]domain.Inspection, error) {
+	return u.repo.ListInspections(ctx, filter)
 }
 
 func (u *AnalyticsUseCase) GetInspectionDetail(ctx context.Context, inspectionID uuid.UUID) (*domain.InspectionDetail, error) {
@@ -43,28 +46,75 @@ func (u *AnalyticsUseCase) GetInspectionDetail(ctx context.Context, inspectionID
 	// Map answers to questions for detail view
 	answerMap := make(map[uuid.UUID]domain.InspectionAnswer)
 	for _, a := range answers {
-		// Replace S3 keys with presigned URLs for viewing
-		for i, key := range a.Photos {
-			url, err := u.storage.GetURL(ctx, "", key)
-			if err == nil {
-				a.Photos[i] = url
+		// Presigned URLs for viewing
+		for i, p := range a.Photos {
+			a.Photos[i].URL = u.photoURL(ctx, p.Key)
+			a.Photos[i].ThumbnailURL = a.Photos[i].URL
+			if p.ThumbnailKey != "" {
+				a.Photos[i].ThumbnailURL = u.photoURL(ctx, p.ThumbnailKey)
 			}
 		}
 		answerMap[a.QuestionID] = a
 	}
 
+	// Questions hidden by show-if conditions were never asked
 	var details []domain.InspectionAnswerDetail
-	for _, q := range questions {
+	wrongSubject := 0
+	for _, q := range visibleQuestions(questions, answers) {
 		details = append(details, domain.InspectionAnswerDetail{
 			Question: q,
 			Answer:   answerMap[q.ID],
 		})
+		wrongSubject += countWrongSubject(answerMap[q.ID].Photos)
 	}
 
-	return &domain.InspectionDetail{
-		Inspection: *inspection,
-		Answers:    details,
-	}, nil
+	reviews, err := u.repo.ListInspectionReviews(ctx, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+
+	detail := &domain.InspectionDetail{
+		Inspection:         *inspection,
+		Answers:            details,
+		Reviews:            reviews,
+		WrongSubjectPhotos: wrongSubject,
+	}
+	if inspection.SignatureKey != "" {
+		url, err := u.storage.GetURL(ctx, "", inspection.SignatureKey)
+		if err == nil {
+			detail.SignatureURL = url
+		}
+	}
+	seal, err := u.repo.GetInspectionSeal(ctx, inspectionID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get seal: %w", err)
+	}
+	detail.Seal = seal
+
+	detail.Duplicates, err = u.ListDuplicatePhotos(ctx, &inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// ListDuplicatePhotos returns the duplicate photo flags newest first with
+// viewable links, only those involving the inspection if one is given.
+func (u *AnalyticsUseCase) ListDuplicatePhotos(ctx context.Context, inspectionID *uuid.UUID) ([]domain.DuplicatePhoto, error) {
+	flags, err := u.repo.ListDuplicatePhotos(ctx, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicate photos: %w", err)
+	}
+	for i := range flags {
+		for _, ref := range []*domain.PhotoRef{&flags[i].Photo, &flags[i].Match} {
+			ref.URL = u.photoURL(ctx, ref.Key)
+			ref.ThumbnailURL = ref.URL
+			if ref.ThumbnailKey != "" {
+				ref.ThumbnailURL = u.photoURL(ctx, ref.ThumbnailKey)
+			}
+		}
+	}
+	return flags, nil
 }
 
 func (u *AnalyticsUseCase) ExportToCSV(ctx context.Context, inspectionID uuid.UUID) ([]byte, error) {
@@ -77,35 +127,69 @@ func (u *AnalyticsUseCase) ExportToCSV(ctx context.Context, inspectionID uuid.UU
 	w := csv.NewWriter(&buf)
 
 	// Header
-	w.Write([]string{"Machine Serial", "Inspector", "Status", "Started At", "Finished At"})
-	finishedAt := ""
+	w.Write([]string{"Machine Serial", "Inspector", "Status", "Verdict", "Started At", "Finished At", "Review", "Reviewed By", "Reviewed At", "Signed By", "Signed At"})
+	finishedAt, signedAt := "", ""
 	if detail.Inspection.FinishedAt != nil {
 		finishedAt = detail.Inspection.FinishedAt.Format("02.01.2006 15:04")
 	}
+	if detail.Inspection.SignedAt != nil {
+		signedAt = detail.Inspection.SignedAt.Format("02.01.2006 15:04")
+	}
+	review := lastReview(detail.Reviews)
 	w.Write([]string{
 		detail.Inspection.MachineSerial,
 		detail.Inspection.InspectorName,
 		string(detail.Inspection.Status),
+		string(detail.Inspection.Verdict),
 		detail.Inspection.StartedAt.Format("02.01.2006 15:04"),
 		finishedAt,
+		string(review.Decision),
+		review.ReviewerName,
+		formatReviewTime(review),
+		detail.Inspection.SignedBy,
+		signedAt,
 	})
 
 	w.Write([]string{}) // Empty line
-	w.Write([]string{"Question", "Comment", "Photos"})
+	w.Write([]string{"Question", "Verdict", "Value", "Comment", "Photos", "Rework Comment"})
 
 	for _, d := range detail.Answers {
 		photos := ""
 		for _, p := range d.Answer.Photos {
-			photos += p + " "
+			photos += p.URL + " "
 		}
 		w.Write([]string{
 			d.Question.Text,
+			string(d.Answer.Verdict),
+			formatAnswerValue(d.Question, d.Answer.Value),
 			d.Answer.Comment,
 			photos,
+			d.Answer.ReworkComment,
 		})
 	}
 
+	if len(detail.Reviews) > 0 {
+		questionText := make(map[uuid.UUID]string, len(detail.Answers))
+		for _, d := range detail.Answers {
+			questionText[d.Question.ID] = d.Question.Text
+		}
+
+		w.Write([]string{})
+		w.Write([]string{"Review", "Reviewed By", "Reviewed At", "Comment", "Question", "Rework Comment"})
+		for _, rv := range detail.Reviews {
+			w.Write([]string{string(rv.Decision), rv.ReviewerName, formatReviewTime(rv), rv.Comment, "", ""})
+			for _, rework := range rv.Rework {
+				w.Write([]string{"", "", "", "", questionText[rework.QuestionID], rework.Comment})
+			}
+		}
+	}
+
 	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+	// Only exports that were produced are recorded
+	recordAudit(ctx, u.repo, domain.AuditInspectionExport, domain.AuditEntityInspection, inspectionID.String(), nil, map[string]string{"Format": "csv"})
 	return buf.Bytes(), nil
 }
 
@@ -129,22 +213,55 @@ func (u *AnalyticsUseCase) ExportToPDF(ctx context.Context, inspectionID uuid.UU
 	pdf.Ln(8)
 	pdf.Cell(40, 10, fmt.Sprintf("Status: %s", detail.Inspection.Status))
 	pdf.Ln(8)
+	if detail.Inspection.Verdict != "" {
+		pdf.Cell(40, 10, fmt.Sprintf("Verdict: %s", verdictLabel(detail.Inspection.Verdict)))
+		pdf.Ln(8)
+	}
 	pdf.Cell(40, 10, fmt.Sprintf("Started: %s", detail.Inspection.StartedAt.Format("02.01.2006 15:04")))
-	pdf.Ln(15)
+	pdf.Ln(8)
+	for _, rv := range detail.Reviews {
+		line := fmt.Sprintf("Review: %s by %s, %s", rv.Decision, rv.ReviewerName, formatReviewTime(rv))
+		if rv.Comment != "" {
+			line += ": " + rv.Comment
+		}
+		pdf.MultiCell(0, 8, line, "", "", false)
+	}
+	pdf.Ln(7)
 
 	for _, d := range detail.Answers {
 		pdf.SetFont("Arial", "B", 12)
 		pdf.MultiCell(0, 8, d.Question.Text, "", "", false)
 
+		if d.Answer.Verdict != "" {
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(0, 6, fmt.Sprintf("Result: %s", verdictLabel(d.Answer.Verdict)))
+			pdf.Ln(6)
+		}
+		if value := formatAnswerValue(d.Question, d.Answer.Value); value != "" {
+			pdf.SetFont("Arial", "", 10)
+			pdf.MultiCell(0, 6, fmt.Sprintf("Value: %s", value), "", "", false)
+		}
+
 		pdf.SetFont("Arial", "I", 10)
 		if d.Answer.Comment != "" {
 			pdf.MultiCell(0, 6, fmt.Sprintf("Comment: %s", d.Answer.Comment), "", "", false)
 		}
+		if d.Answer.ReworkComment != "" {
+			pdf.MultiCell(0, 6, fmt.Sprintf("Rework requested: %s", d.Answer.ReworkComment), "", "", false)
+		}
 
 		if len(d.Answer.Photos) > 0 {
 			pdf.SetFont("Arial", "", 10)
 			pdf.Cell(0, 6, fmt.Sprintf("Photos: %d uploaded", len(d.Answer.Photos)))
 			pdf.Ln(6)
+			if stale := countStale(d.Answer.Photos); stale > 0 {
+				pdf.Cell(0, 6, fmt.Sprintf("Taken before the inspection started: %d", stale))
+				pdf.Ln(6)
+			}
+			if wrong := countWrongSubject(d.Answer.Photos); wrong > 0 {
+				pdf.Cell(0, 6, fmt.Sprintf("Possibly wrong subject (unlike the reference): %d", wrong))
+				pdf.Ln(6)
+			}
 		} else {
 			pdf.SetFont("Arial", "", 10)
 			pdf.Cell(0, 6, "No photos uploaded")
@@ -153,11 +270,140 @@ func (u *AnalyticsUseCase) ExportToPDF(ctx context.Context, inspectionID uuid.UU
 		pdf.Ln(5)
 	}
 
+	if detail.Inspection.SignatureKey != "" {
+		if err := u.addSignature(ctx, pdf, &detail.Inspection); err != nil {
+			return nil, err
+		}
+	}
+	if detail.Seal != nil {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "", 8)
+		pdf.MultiCell(0, 4, fmt.Sprintf("Integrity seal #%d: %s", detail.Seal.Sequence, detail.Seal.Hash), "", "", false)
+		pdf.MultiCell(0, 4, fmt.Sprintf("Previous seal: %s", detail.Seal.PrevHash), "", "", false)
+	}
+
 	var buf bytes.Buffer
 	err = pdf.Output(&buf)
 	if err != nil {
 		return nil, err
 	}
 
+	recordAudit(ctx, u.repo, domain.AuditInspectionExport, domain.AuditEntityInspection, inspectionID.String(), nil, map[string]string{"Format": "pdf"})
 	return buf.Bytes(), nil
 }
+
+// addSignature places the inspector's signature image, name and signing
+// time at the bottom of the report.
+func (u *AnalyticsUseCase) addSignature(ctx context.Context, pdf *gofpdf.Fpdf, inspection *domain.Inspection) error {
+	file, err := u.storage.Open(ctx, "", inspection.SignatureKey)
+	if err != nil {
+		return fmt.Errorf("failed to download signature: %w", err)
+	}
+	defer file.Close()
+	pdf.RegisterImageOptionsReader("signature", gofpdf.ImageOptions{ImageType: "PNG"}, file)
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	// Keep the caption and the image on one page
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+signatureHeight+20 > pageHeight-bottom {
+		pdf.AddPage()
+	}
+
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(0, 8, "Inspector signature")
+	pdf.Ln(8)
+	pdf.ImageOptions("signature", pdf.GetX(), pdf.GetY(), 0, signatureHeight, true, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	signedAt := ""
+	if inspection.SignedAt != nil {
+		signedAt = inspection.SignedAt.Format("02.01.2006 15:04")
+	}
+	pdf.Cell(0, 6, fmt.Sprintf("Signed by: %s, %s", inspection.SignedBy, signedAt))
+	pdf.Ln(6)
+	return nil
+}
+
+// signatureHeight is the height of the signature image in the PDF, in mm.
+const signatureHeight = 25
+
+// lastReview returns the latest review, or a zero review when there is none.
+func lastReview(reviews []domain.InspectionReview) domain.InspectionReview {
+	if len(reviews) == 0 {
+		return domain.InspectionReview{}
+	}
+	return reviews[len(reviews)-1]
+}
+
+// photoURL returns a viewable link to a stored photo, or its key if the
+// link cannot be made.
+func (u *AnalyticsUseCase) photoURL(ctx context.Context, key string) string {
+	url, err := u.storage.GetURL(ctx, "", key)
+	if err != nil {
+		return key
+	}
+	return url
+}
+
+// countStale counts photos taken before the inspection started.
+func countStale(photos []domain.AnswerPhoto) int {
+	n := 0
+	for _, p := range photos {
+		if p.Stale {
+			n++
+		}
+	}
+	return n
+}
+
+// countWrongSubject counts photos unlike the reference images of their question.
+func countWrongSubject(photos []domain.AnswerPhoto) int {
+	n := 0
+	for _, p := range photos {
+		if p.WrongSubject {
+			n++
+		}
+	}
+	return n
+}
+
+func formatReviewTime(rv domain.InspectionReview) string {
+	if rv.CreatedAt.IsZero() {
+		return ""
+	}
+	return rv.CreatedAt.Format("02.01.2006 15:04")
+}
+
+func verdictLabel(v domain.Verdict) string {
+	switch v {
+	case domain.VerdictPass:
+		return "PASS"
+	case domain.VerdictFail:
+		return "FAIL"
+	case domain.VerdictNotApplicable:
+		return "N/A"
+	}
+	return string(v)
+}
+
+// formatAnswerValue renders a typed answer value for exports.
+func formatAnswerValue(q domain.Question, v *domain.AnswerValue) string {
+	if v == nil {
+		return ""
+	}
+	switch {
+	case v.Bool != nil:
+		if *v.Bool {
+			return "yes"
+		}
+		return "no"
+	case v.Number != nil:
+		return strings.TrimSpace(strconv.FormatFloat(*v.Number, 'f', -1, 64) + " " + q.Config.Unit)
+	case len(v.Choices) > 0:
+		return strings.Join(v.Choices, "; ")
+	default:
+		return v.Text
+	}
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+// auditPageSize limits the entries shown on one page of the audit log.
+const auditPageSize = 200
+
+type AuditUseCase struct {
+	repo domain.ChecklistRepository
+}
+
+func NewAuditUseCase(repo domain.ChecklistRepository) *AuditUseCase {
+	return &AuditUseCase{repo: repo}
+}
+
+// ListEntries returns the newest audit entries matching the filter.
+func (u *AuditUseCase) ListEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > auditPageSize {
+		filter.Limit = auditPageSize
+	}
+	return u.repo.ListAuditEntries(ctx, filter)
+}
+
+// recordAudit appends an entry for the actor of the context. Before and
+// after are stored as JSON; pass nil when there is no snapshot. The change
+// has already happened, so a failure to record it is logged, not returned.
+func recordAudit(ctx context.Context, repo domain.ChecklistRepository, action domain.AuditAction, entityType, entityID string, before, after any) {
+	entry := &domain.AuditEntry{
+		ID:         uuid.New(),
+		Actor:      domain.ActorFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditSnapshot(before),
+		After:      auditSnapshot(after),
+		CreatedAt:  time.Now(),
+	}
+	if err := repo.CreateAuditEntry(ctx, entry); err != nil {
+		log.Printf("Audit: failed to record %s of %s %s: %v", action, entityType, entityID, err)
+	}
+}
+
+func auditSnapshot(v any) []byte {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Audit: failed to encode snapshot: %v", err)
+		return nil
+	}
+	return data
+}
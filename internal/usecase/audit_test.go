@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"MVP_checklist/internal/domain"
+)
+
+func TestAuditSnapshot(t *testing.T) {
+	var answer *domain.InspectionAnswer
+
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "No snapshot", value: nil, expected: ""},
+		{name: "Nil pointer", value: answer, expected: "null"},
+		{name: "Map", value: map[string]string{"Format": "csv"}, expected: `{"Format":"csv"}`},
+		{name: "Unencodable", value: func() {}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(auditSnapshot(tt.value)); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	if got := domain.ActorFromContext(context.Background()); got.Kind != domain.ActorSystem {
+		t.Errorf("expected system actor by default, got %q", got.Kind)
+	}
+
+	ctx := domain.WithActor(context.Background(), domain.Actor{Kind: domain.ActorAdmin, Name: "A"})
+	if got := domain.ActorFromContext(ctx); got.Kind != domain.ActorAdmin || got.Name != "A" {
+		t.Errorf("expected admin A, got %q %q", got.Kind, got.Name)
+	}
+}
@@ -0,0 +1,464 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPINLength      = 4
+	minPasswordLength = 8
+	maxLoginFailures  = 5
+	loginLockout      = 5 * time.Minute
+)
+
+type AuthConfig struct {
+	SessionTTL time.Duration
+}
+
+type AuthUseCase struct {
+	repo domain.ChecklistRepository
+	cfg  AuthConfig
+
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	prunedAt time.Time
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// dummySecretHash is compared against when no account matches a login, so
+// that unknown names take as long to reject as wrong PINs.
+var dummySecretHash = sync.OnceValue(func() string {
+	hash, _ := hashSecret("no such account")
+	return hash
+})
+
+func NewAuthUseCase(repo domain.ChecklistRepository, cfg AuthConfig) *AuthUseCase {
+	return &AuthUseCase{repo: repo, cfg: cfg, failures: make(map[string]*loginFailures)}
+}
+
+func (u *AuthUseCase) SessionTTL() time.Duration {
+	return u.cfg.SessionTTL
+}
+
+func hashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash secret: %w", err)
+	}
+	return string(hash), nil
+}
+
+func checkSecret(hash, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validateRoles(roles []domain.Role) error {
+	if len(roles) == 0 {
+		return &domain.ValidationError{Message: "Выберите хотя бы одну роль"}
+	}
+	for _, role := range roles {
+		if !role.IsValid() {
+			return &domain.ValidationError{Message: fmt.Sprintf("Неизвестная роль %q", role)}
+		}
+	}
+	return nil
+}
+
+// inspectorSnapshot is what the audit log keeps of an inspector, leaving out
+// the PIN hash.
+type inspectorSnapshot struct {
+	Name       string
+	Roles      []domain.Role
+	IsActive   bool
+	PINChanged bool `json:",omitempty"`
+}
+
+func newInspectorSnapshot(i *domain.Inspector) inspectorSnapshot {
+	return inspectorSnapshot{Name: i.Name, Roles: i.Roles, IsActive: i.IsActive}
+}
+
+// adminUserSnapshot is what the audit log keeps of an admin user, leaving
+// out the password hash.
+type adminUserSnapshot struct {
+	Login           string
+	Name            string
+	Role            domain.AdminRole
+	IsActive        bool
+	PasswordChanged bool `json:",omitempty"`
+}
+
+func newAdminUserSnapshot(u *domain.AdminUser) adminUserSnapshot {
+	return adminUserSnapshot{Login: u.Login, Name: u.Name, Role: u.Role, IsActive: u.IsActive}
+}
+
+// loginKey identifies the login attempts of a name from one client address,
+// so that failures elsewhere cannot lock the account out.
+func loginKey(kind, name, ip string) string {
+	return kind + ":" + strings.ToLower(strings.TrimSpace(name)) + "@" + ip
+}
+
+// checkLockout refuses logins for a key that failed too many times in a row,
+// which keeps short PINs from being guessed.
+func (u *AuthUseCase) checkLockout(key string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if f, ok := u.failures[key]; ok && time.Now().Before(f.lockedUntil) {
+		return domain.ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (u *AuthUseCase) recordLogin(key string, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if ok {
+		delete(u.failures, key)
+		return
+	}
+	now := time.Now()
+	u.pruneFailures(now)
+	f, exists := u.failures[key]
+	if !exists || now.Sub(f.lastFailure) > loginLockout {
+		f = &loginFailures{}
+		u.failures[key] = f
+	}
+	f.count++
+	f.lastFailure = now
+	if f.count >= maxLoginFailures {
+		f.count = 0
+		f.lockedUntil = now.Add(loginLockout)
+	}
+}
+
+// pruneFailures forgets keys whose failures and lockout have expired, at
+// most once per lockout period. The caller holds u.mu.
+func (u *AuthUseCase) pruneFailures(now time.Time) {
+	if now.Sub(u.prunedAt) < loginLockout {
+		return
+	}
+	u.prunedAt = now
+	for key, f := range u.failures {
+		if now.Sub(f.lastFailure) > loginLockout && now.After(f.lockedUntil) {
+			delete(u.failures, key)
+		}
+	}
+}
+
+func (u *AuthUseCase) createSession(ctx context.Context, kind domain.SessionKind, subjectID uuid.UUID) (string, *domain.Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	session := &domain.Session{
+		TokenHash: hashToken(token),
+		Kind:      kind,
+		SubjectID: subjectID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(u.cfg.SessionTTL),
+	}
+	if err := u.repo.CreateSession(ctx, session); err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return token, session, nil
+}
+
+func (u *AuthUseCase) getSession(ctx context.Context, kind domain.SessionKind, token string) (*domain.Session, error) {
+	if token == "" {
+		return nil, fmt.Errorf("session: %w", domain.ErrNotFound)
+	}
+	session, err := u.repo.GetSession(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if session.Kind != kind {
+		return nil, fmt.Errorf("session: %w", domain.ErrNotFound)
+	}
+	return session, nil
+}
+
+func (u *AuthUseCase) Logout(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	return u.repo.DeleteSession(ctx, hashToken(token))
+}
+
+func (u *AuthUseCase) CreateInspector(ctx context.Context, name, pin string, roles []domain.Role) (*domain.Inspector, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &domain.ValidationError{Message: "Укажите ФИО исполнителя"}
+	}
+	if len(pin) < minPINLength {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("PIN-код должен быть не короче %d символов", minPINLength)}
+	}
+	if err := validateRoles(roles); err != nil {
+		return nil, err
+	}
+	if _, err := u.repo.GetInspectorByName(ctx, name); err == nil {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Исполнитель «%s» уже есть", name)}
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	pinHash, err := hashSecret(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	inspector := &domain.Inspector{
+		ID:        uuid.New(),
+		Name:      name,
+		PINHash:   pinHash,
+		Roles:     roles,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	if err := u.repo.CreateInspector(ctx, inspector); err != nil {
+		return nil, fmt.Errorf("failed to create inspector: %w", err)
+	}
+	recordAudit(ctx, u.repo, domain.AuditInspectorCreate, domain.AuditEntityInspector, inspector.ID.String(), nil, newInspectorSnapshot(inspector))
+	return inspector, nil
+}
+
+// UpdateInspector changes the inspector's roles and status; the PIN is only
+// replaced when a new one is given.
+func (u *AuthUseCase) UpdateInspector(ctx context.Context, id uuid.UUID, roles []domain.Role, isActive bool, newPIN string) (*domain.Inspector, error) {
+	if err := validateRoles(roles); err != nil {
+		return nil, err
+	}
+
+	inspector, err := u.repo.GetInspectorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := newInspectorSnapshot(inspector)
+
+	if newPIN != "" {
+		if len(newPIN) < minPINLength {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("PIN-код должен быть не короче %d символов", minPINLength)}
+		}
+		if inspector.PINHash, err = hashSecret(newPIN); err != nil {
+			return nil, err
+		}
+	}
+	inspector.Roles = roles
+	inspector.IsActive = isActive
+
+	if err := u.repo.UpdateInspector(ctx, inspector); err != nil {
+		return nil, fmt.Errorf("failed to update inspector: %w", err)
+	}
+	after := newInspectorSnapshot(inspector)
+	after.PINChanged = newPIN != ""
+	recordAudit(ctx, u.repo, domain.AuditInspectorUpdate, domain.AuditEntityInspector, inspector.ID.String(), before, after)
+	return inspector, nil
+}
+
+func (u *AuthUseCase) ListInspectors(ctx context.Context) ([]domain.Inspector, error) {
+	return u.repo.ListInspectors(ctx)
+}
+
+// LoginInspector checks the name and PIN entered from the client address ip
+// and returns a new session token.
+func (u *AuthUseCase) LoginInspector(ctx context.Context, name, pin, ip string) (string, *domain.Inspector, error) {
+	key := loginKey("inspector", name, ip)
+	if err := u.checkLockout(key); err != nil {
+		return "", nil, err
+	}
+
+	inspector, err := u.repo.GetInspectorByName(ctx, strings.TrimSpace(name))
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return "", nil, err
+	}
+	hash := dummySecretHash()
+	if inspector != nil {
+		hash = inspector.PINHash
+	}
+	if !checkSecret(hash, pin) || inspector == nil || !inspector.IsActive {
+		u.recordLogin(key, false)
+		return "", nil, domain.ErrInvalidCredentials
+	}
+	u.recordLogin(key, true)
+
+	token, _, err := u.createSession(ctx, domain.SessionInspector, inspector.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, inspector, nil
+}
+
+// InspectorFromSession returns the active inspector logged in with the token.
+func (u *AuthUseCase) InspectorFromSession(ctx context.Context, token string) (*domain.Inspector, error) {
+	session, err := u.getSession(ctx, domain.SessionInspector, token)
+	if err != nil {
+		return nil, err
+	}
+	inspector, err := u.repo.GetInspectorByID(ctx, session.SubjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !inspector.IsActive {
+		return nil, domain.ErrForbidden
+	}
+	return inspector, nil
+}
+
+func (u *AuthUseCase) CreateAdminUser(ctx context.Context, login, name, password string, role domain.AdminRole) (*domain.AdminUser, error) {
+	login = strings.TrimSpace(login)
+	name = strings.TrimSpace(name)
+	if login == "" || name == "" {
+		return nil, &domain.ValidationError{Message: "Укажите логин и ФИО"}
+	}
+	if len(password) < minPasswordLength {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Пароль должен быть не короче %d символов", minPasswordLength)}
+	}
+	if !role.IsValid() {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Неизвестная роль %q", role)}
+	}
+	if _, err := u.repo.GetAdminUserByLogin(ctx, login); err == nil {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Логин «%s» уже занят", login)}
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	passwordHash, err := hashSecret(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.AdminUser{
+		ID:           uuid.New(),
+		Login:        login,
+		Name:         name,
+		PasswordHash: passwordHash,
+		Role:         role,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+	}
+	if err := u.repo.CreateAdminUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create admin user: %w", err)
+	}
+	recordAudit(ctx, u.repo, domain.AuditAdminUserCreate, domain.AuditEntityAdminUser, user.ID.String(), nil, newAdminUserSnapshot(user))
+	return user, nil
+}
+
+// UpdateAdminUser changes the role and status of an admin user; the password
+// is only replaced when a new one is given.
+func (u *AuthUseCase) UpdateAdminUser(ctx context.Context, id uuid.UUID, role domain.AdminRole, isActive bool, newPassword string) (*domain.AdminUser, error) {
+	if !role.IsValid() {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Неизвестная роль %q", role)}
+	}
+
+	user, err := u.repo.GetAdminUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := newAdminUserSnapshot(user)
+
+	if newPassword != "" {
+		if len(newPassword) < minPasswordLength {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Пароль должен быть не короче %d символов", minPasswordLength)}
+		}
+		if user.PasswordHash, err = hashSecret(newPassword); err != nil {
+			return nil, err
+		}
+	}
+	user.Role = role
+	user.IsActive = isActive
+
+	if err := u.repo.UpdateAdminUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update admin user: %w", err)
+	}
+	after := newAdminUserSnapshot(user)
+	after.PasswordChanged = newPassword != ""
+	recordAudit(ctx, u.repo, domain.AuditAdminUserUpdate, domain.AuditEntityAdminUser, user.ID.String(), before, after)
+	return user, nil
+}
+
+func (u *AuthUseCase) ListAdminUsers(ctx context.Context) ([]domain.AdminUser, error) {
+	return u.repo.ListAdminUsers(ctx)
+}
+
+// EnsureSuperuser creates the first superuser so a fresh installation can be
+// administered. It does nothing once any admin user exists.
+func (u *AuthUseCase) EnsureSuperuser(ctx context.Context, login, password string) error {
+	users, err := u.repo.ListAdminUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	_, err = u.CreateAdminUser(ctx, login, login, password, domain.AdminSuperuser)
+	return err
+}
+
+// LoginAdmin checks the login and password entered from the client address
+// ip and returns a new session token.
+func (u *AuthUseCase) LoginAdmin(ctx context.Context, login, password, ip string) (string, *domain.AdminUser, error) {
+	key := loginKey("admin", login, ip)
+	if err := u.checkLockout(key); err != nil {
+		return "", nil, err
+	}
+
+	user, err := u.repo.GetAdminUserByLogin(ctx, strings.TrimSpace(login))
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return "", nil, err
+	}
+	hash := dummySecretHash()
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !checkSecret(hash, password) || user == nil || !user.IsActive {
+		u.recordLogin(key, false)
+		return "", nil, domain.ErrInvalidCredentials
+	}
+	u.recordLogin(key, true)
+
+	token, _, err := u.createSession(ctx, domain.SessionAdmin, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, user, nil
+}
+
+// AdminFromSession returns the active admin user logged in with the token.
+func (u *AuthUseCase) AdminFromSession(ctx context.Context, token string) (*domain.AdminUser, error) {
+	session, err := u.getSession(ctx, domain.SessionAdmin, token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := u.repo.GetAdminUserByID(ctx, session.SubjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, domain.ErrForbidden
+	}
+	return user, nil
+}
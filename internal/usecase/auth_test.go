@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestAdminRolePermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     domain.AdminRole
+		perm     domain.Permission
+		expected bool
+	}{
+		{name: "Viewer sees inspections", role: domain.AdminViewer, perm: domain.PermViewInspections, expected: true},
+		{name: "Viewer cannot export", role: domain.AdminViewer, perm: domain.PermExportInspections, expected: false},
+		{name: "QA manager exports", role: domain.AdminQAManager, perm: domain.PermExportInspections, expected: true},
+		{name: "QA manager cancels inspections", role: domain.AdminQAManager, perm: domain.PermCancelInspections, expected: true},
+		{name: "Viewer cannot cancel inspections", role: domain.AdminViewer, perm: domain.PermCancelInspections, expected: false},
+		{name: "QA manager reviews inspections", role: domain.AdminQAManager, perm: domain.PermReviewInspections, expected: true},
+		{name: "Template editor cannot review inspections", role: domain.AdminTemplateEditor, perm: domain.PermReviewInspections, expected: false},
+		{name: "Superuser views audit log", role: domain.AdminSuperuser, perm: domain.PermViewAudit, expected: true},
+		{name: "QA manager cannot view audit log", role: domain.AdminQAManager, perm: domain.PermViewAudit, expected: false},
+		{name: "QA manager verifies integrity", role: domain.AdminQAManager, perm: domain.PermVerifyIntegrity, expected: true},
+		{name: "Viewer cannot verify integrity", role: domain.AdminViewer, perm: domain.PermVerifyIntegrity, expected: false},
+		{name: "QA manager manages inspectors", role: domain.AdminQAManager, perm: domain.PermManageInspectors, expected: true},
+		{name: "QA manager cannot edit templates", role: domain.AdminQAManager, perm: domain.PermEditTemplates, expected: false},
+		{name: "Template editor edits templates", role: domain.AdminTemplateEditor, perm: domain.PermEditTemplates, expected: true},
+		{name: "Template editor cannot manage admins", role: domain.AdminTemplateEditor, perm: domain.PermManageAdmins, expected: false},
+		{name: "Superuser manages admins", role: domain.AdminSuperuser, perm: domain.PermManageAdmins, expected: true},
+		{name: "Unknown role has no access", role: domain.AdminRole("guest"), perm: domain.PermViewInspections, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.Can(tt.perm); got != tt.expected {
+				t.Errorf("%s.Can(%s) = %v, expected %v", tt.role, tt.perm, got, tt.expected)
+			}
+		})
+	}
+}
+
+// accountRepo stores created accounts in memory; a non-nil err makes every
+// write fail as the database would.
+type accountRepo struct {
+	domain.ChecklistRepository
+	inspectors []domain.Inspector
+	admins     []domain.AdminUser
+	audit      []domain.AuditEntry
+	err        error
+}
+
+func (r *accountRepo) GetInspectorByID(ctx context.Context, id uuid.UUID) (*domain.Inspector, error) {
+	for _, i := range r.inspectors {
+		if i.ID == id {
+			return &i, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *accountRepo) UpdateInspector(ctx context.Context, i *domain.Inspector) error {
+	return r.err
+}
+
+func (r *accountRepo) CreateAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	r.audit = append(r.audit, *entry)
+	return nil
+}
+
+func (r *accountRepo) GetInspectorByName(ctx context.Context, name string) (*domain.Inspector, error) {
+	for _, i := range r.inspectors {
+		if strings.EqualFold(i.Name, name) {
+			return &i, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *accountRepo) CreateInspector(ctx context.Context, i *domain.Inspector) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.inspectors = append(r.inspectors, *i)
+	return nil
+}
+
+func (r *accountRepo) GetAdminUserByLogin(ctx context.Context, login string) (*domain.AdminUser, error) {
+	for _, u := range r.admins {
+		if strings.EqualFold(u.Login, login) {
+			return &u, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *accountRepo) CreateAdminUser(ctx context.Context, u *domain.AdminUser) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.admins = append(r.admins, *u)
+	return nil
+}
+
+func TestCreateInspectorErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		inspector  string
+		pin        string
+		roles      []domain.Role
+		repoErr    error
+		validation string
+	}{
+		{name: "Missing name", inspector: " ", pin: "1234", roles: []domain.Role{domain.RoleOTK}, validation: "Укажите ФИО исполнителя"},
+		{name: "Short PIN", inspector: "Петров", pin: "12", roles: []domain.Role{domain.RoleOTK}, validation: "PIN-код должен быть не короче 4 символов"},
+		{name: "No roles", inspector: "Петров", pin: "1234", validation: "Выберите хотя бы одну роль"},
+		{name: "Unknown role", inspector: "Петров", pin: "1234", roles: []domain.Role{"guest"}, validation: `Неизвестная роль "guest"`},
+		{name: "Duplicate name", inspector: "иванов", pin: "1234", roles: []domain.Role{domain.RoleOTK}, validation: "Исполнитель «иванов» уже есть"},
+		{name: "Database failure", inspector: "Петров", pin: "1234", roles: []domain.Role{domain.RoleOTK}, repoErr: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &accountRepo{inspectors: []domain.Inspector{{Name: "Иванов"}}, err: tt.repoErr}
+			_, err := NewAuthUseCase(repo, AuthConfig{}).CreateInspector(context.Background(), tt.inspector, tt.pin, tt.roles)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var validationErr *domain.ValidationError
+			isValidation := errors.As(err, &validationErr)
+			if tt.validation == "" {
+				if isValidation {
+					t.Errorf("expected a database error, got validation error %q", validationErr.Message)
+				}
+				return
+			}
+			if !isValidation {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if validationErr.Message != tt.validation {
+				t.Errorf("expected message %q, got %q", tt.validation, validationErr.Message)
+			}
+		})
+	}
+}
+
+func TestCreateAdminUserErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		login      string
+		password   string
+		role       domain.AdminRole
+		repoErr    error
+		validation string
+	}{
+		{name: "Missing login", login: "", password: "password1", role: domain.AdminViewer, validation: "Укажите логин и ФИО"},
+		{name: "Short password", login: "petrov", password: "123", role: domain.AdminViewer, validation: "Пароль должен быть не короче 8 символов"},
+		{name: "Unknown role", login: "petrov", password: "password1", role: "guest", validation: `Неизвестная роль "guest"`},
+		{name: "Duplicate login", login: "Admin", password: "password1", role: domain.AdminViewer, validation: "Логин «Admin» уже занят"},
+		{name: "Database failure", login: "petrov", password: "password1", role: domain.AdminViewer, repoErr: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &accountRepo{admins: []domain.AdminUser{{Login: "admin"}}, err: tt.repoErr}
+			_, err := NewAuthUseCase(repo, AuthConfig{}).CreateAdminUser(context.Background(), tt.login, "Петров", tt.password, tt.role)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var validationErr *domain.ValidationError
+			isValidation := errors.As(err, &validationErr)
+			if tt.validation == "" {
+				if isValidation {
+					t.Errorf("expected a database error, got validation error %q", validationErr.Message)
+				}
+				return
+			}
+			if !isValidation {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if validationErr.Message != tt.validation {
+				t.Errorf("expected message %q, got %q", tt.validation, validationErr.Message)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	pinHash, err := hashSecret("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &accountRepo{inspectors: []domain.Inspector{{Name: "Иванов", PINHash: pinHash, IsActive: true}}}
+	uc := NewAuthUseCase(repo, AuthConfig{})
+	ctx := context.Background()
+
+	for i := 0; i < maxLoginFailures; i++ {
+		if _, _, err := uc.LoginInspector(ctx, "Иванов", "0000", "203.0.113.7"); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i+1, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		user string
+		ip   string
+		want error
+	}{
+		{name: "Same client is locked out", user: "иванов", ip: "203.0.113.7", want: domain.ErrTooManyAttempts},
+		{name: "Other client is not", user: "Иванов", ip: "198.51.100.20", want: domain.ErrInvalidCredentials},
+		{name: "Unknown name", user: "Сидоров", ip: "198.51.100.20", want: domain.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := uc.LoginInspector(ctx, tt.user, "0000", tt.ip); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPruneLoginFailures(t *testing.T) {
+	now := time.Now()
+	uc := NewAuthUseCase(nil, AuthConfig{})
+	uc.failures = map[string]*loginFailures{
+		"expired":  {count: 2, lastFailure: now.Add(-2 * loginLockout)},
+		"recent":   {count: 2, lastFailure: now.Add(-time.Minute)},
+		"locked":   {lastFailure: now.Add(-2 * loginLockout), lockedUntil: now.Add(time.Minute)},
+		"unlocked": {lastFailure: now.Add(-2 * loginLockout), lockedUntil: now.Add(-time.Minute)},
+	}
+
+	uc.pruneFailures(now)
+	var kept []string
+	for key := range uc.failures {
+		kept = append(kept, key)
+	}
+	slices.Sort(kept)
+	if want := []string{"locked", "recent"}; !slices.Equal(kept, want) {
+		t.Errorf("expected %v to be kept, got %v", want, kept)
+	}
+}
+
+func TestUpdateInspectorAudit(t *testing.T) {
+	pinHash, err := hashSecret("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := uuid.New()
+	repo := &accountRepo{inspectors: []domain.Inspector{{ID: id, Name: "Иванов", PINHash: pinHash, Roles: []domain.Role{domain.RoleOTK}, IsActive: true}}}
+
+	if _, err := NewAuthUseCase(repo, AuthConfig{}).UpdateInspector(context.Background(), id, []domain.Role{domain.RoleOTK, domain.RoleAds}, true, "5678"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repo.audit) != 1 {
+		t.Fatalf("expected one audit entry, got %d", len(repo.audit))
+	}
+	entry := repo.audit[0]
+	if entry.Action != domain.AuditInspectorUpdate || entry.EntityID != id.String() {
+		t.Errorf("expected %s of %s, got %s of %s", domain.AuditInspectorUpdate, id, entry.Action, entry.EntityID)
+	}
+	if want := `{"Name":"Иванов","Roles":["OTK","ADS"],"IsActive":true,"PINChanged":true}`; string(entry.After) != want {
+		t.Errorf("expected after %s, got %s", want, entry.After)
+	}
+	if strings.Contains(string(entry.Before)+string(entry.After), "$2") {
+		t.Errorf("audit entry contains a PIN hash: %s %s", entry.Before, entry.After)
+	}
+}
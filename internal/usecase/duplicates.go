@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// duplicatePhotoDistance is how many bits perceptual hashes of near-identical
+// photos may differ in. It must stay below four for the repository's search
+// by 16-bit quarters to find every match.
+const duplicatePhotoDistance = 3
+
+// flagDuplicatePhotos flags new photos near-identical to photos of other
+// machines. The answer is already saved, so failures are only logged.
+func (u *InspectionUseCase) flagDuplicatePhotos(ctx context.Context, inspection *domain.Inspection, photos []domain.AnswerPhoto) {
+	for _, p := range photos {
+		// A uniform image has no features to compare
+		if p.PerceptualHash == 0 {
+			continue
+		}
+		candidates, err := u.repo.FindSimilarPhotos(ctx, p.PerceptualHash, inspection.MachineSerial)
+		if err != nil {
+			log.Printf("Duplicates: failed to find photos similar to %s: %v", p.Key, err)
+			continue
+		}
+		photo := domain.PhotoRef{Key: p.Key, ThumbnailKey: p.ThumbnailKey, InspectionID: inspection.ID, MachineSerial: inspection.MachineSerial}
+		for _, flag := range duplicateFlags(photo, p.PerceptualHash, candidates, time.Now()) {
+			if err := u.repo.CreateDuplicatePhoto(ctx, &flag); err != nil {
+				log.Printf("Duplicates: failed to flag %s: %v", p.Key, err)
+			}
+		}
+	}
+}
+
+// duplicateFlags flags the candidates whose hash is within
+// duplicatePhotoDistance of the photo's.
+func duplicateFlags(photo domain.PhotoRef, hash int64, candidates []domain.HashedPhoto, now time.Time) []domain.DuplicatePhoto {
+	var flags []domain.DuplicatePhoto
+	for _, c := range candidates {
+		if c.MachineSerial == photo.MachineSerial {
+			continue
+		}
+		if d := hashDistance(hash, c.Hash); d <= duplicatePhotoDistance {
+			flags = append(flags, domain.DuplicatePhoto{ID: uuid.New(), Photo: photo, Match: c.PhotoRef, Distance: d, CreatedAt: now})
+		}
+	}
+	return flags
+}
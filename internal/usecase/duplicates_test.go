@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestDuplicateFlags(t *testing.T) {
+	photo := domain.PhotoRef{Key: "new.jpg", InspectionID: uuid.New(), MachineSerial: "AB1"}
+	const hash = int64(0x0F0F_0F0F_0F0F_0F0F)
+	candidate := func(serial string, hash int64) domain.HashedPhoto {
+		return domain.HashedPhoto{PhotoRef: domain.PhotoRef{Key: serial + ".jpg", InspectionID: uuid.New(), MachineSerial: serial}, Hash: hash}
+	}
+
+	tests := []struct {
+		name       string
+		candidates []domain.HashedPhoto
+		expected   []string // matched keys
+		distances  []int
+	}{
+		{name: "Same photo", candidates: []domain.HashedPhoto{candidate("AB2", hash)}, expected: []string{"AB2.jpg"}, distances: []int{0}},
+		{name: "Near-identical photo", candidates: []domain.HashedPhoto{candidate("AB2", hash^0b10101)}, expected: []string{"AB2.jpg"}, distances: []int{3}},
+		{name: "Different photo", candidates: []domain.HashedPhoto{candidate("AB2", hash^0b11110000)}, expected: nil},
+		{name: "Same machine", candidates: []domain.HashedPhoto{candidate("AB1", hash)}, expected: nil},
+		{name: "Only close ones", candidates: []domain.HashedPhoto{candidate("AB2", ^hash), candidate("AB3", hash^1)}, expected: []string{"AB3.jpg"}, distances: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := duplicateFlags(photo, hash, tt.candidates, time.Now())
+			if len(flags) != len(tt.expected) {
+				t.Fatalf("expected %d flags, got %d", len(tt.expected), len(flags))
+			}
+			for i, f := range flags {
+				if f.Photo != photo || f.Match.Key != tt.expected[i] || f.Distance != tt.distances[i] {
+					t.Errorf("expected %s at distance %d, got %+v", tt.expected[i], tt.distances[i], f)
+				}
+			}
+		})
+	}
+}
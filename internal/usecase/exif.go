@@ -0,0 +1,227 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"MVP_checklist/internal/domain"
+)
+
+// EXIF tags read from a photo
+const (
+	exifTagMake             = 0x010F
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagDateTimeOriginal = 0x9003
+	exifTagOffsetOriginal   = 0x9011
+	gpsTagLatitudeRef       = 0x0001
+	gpsTagLatitude          = 0x0002
+	gpsTagLongitudeRef      = 0x0003
+	gpsTagLongitude         = 0x0004
+)
+
+// exifTypeSizes is the size in bytes of one value of each TIFF field type.
+var exifTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+type exifEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// exifReader reads IFDs from the TIFF structure of an EXIF segment.
+type exifReader struct {
+	tiff  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF reads how a JPEG photo was taken from its EXIF segment. It is
+// best effort: a photo without EXIF or with damaged EXIF yields zero fields.
+func parseEXIF(data []byte) domain.PhotoMetadata {
+	var meta domain.PhotoMetadata
+	r, ok := newExifReader(data)
+	if !ok {
+		return meta
+	}
+
+	ifd0 := r.readIFD(r.order.Uint32(r.tiff[4:8]))
+	meta.Device = deviceName(r.ascii(ifd0[exifTagMake]), r.ascii(ifd0[exifTagModel]))
+	if o := r.uint(ifd0[exifTagOrientation]); o >= 1 && o <= 8 {
+		meta.Orientation = int(o)
+	}
+
+	taken := r.ascii(ifd0[exifTagDateTime])
+	offset := ""
+	if e, ok := ifd0[exifTagExifIFD]; ok {
+		exif := r.readIFD(r.uint(e))
+		if original := r.ascii(exif[exifTagDateTimeOriginal]); original != "" {
+			taken = original
+		}
+		offset = r.ascii(exif[exifTagOffsetOriginal])
+	}
+	meta.TakenAt = exifTime(taken, offset)
+
+	if e, ok := ifd0[exifTagGPSIFD]; ok {
+		gps := r.readIFD(r.uint(e))
+		meta.Latitude = r.coordinate(gps[gpsTagLatitude], r.ascii(gps[gpsTagLatitudeRef]), "S")
+		meta.Longitude = r.coordinate(gps[gpsTagLongitude], r.ascii(gps[gpsTagLongitudeRef]), "W")
+	}
+	return meta
+}
+
+// newExifReader finds the APP1 EXIF segment of a JPEG image.
+func newExifReader(data []byte) (*exifReader, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, false
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // fill byte
+			continue
+		}
+		// Metadata segments come before the image data
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, false
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return nil, false
+}
+
+func parseTIFF(tiff []byte) (*exifReader, bool) {
+	if len(tiff) < 8 {
+		return nil, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return nil, false
+	}
+	return &exifReader{tiff: tiff, order: order}, true
+}
+
+// readIFD returns the entries of the IFD at offset by tag. Entries pointing
+// outside the data are skipped.
+func (r *exifReader) readIFD(offset uint32) map[uint16]exifEntry {
+	entries := make(map[uint16]exifEntry)
+	if uint64(offset)+2 > uint64(len(r.tiff)) {
+		return entries
+	}
+	count := uint32(r.order.Uint16(r.tiff[offset:]))
+	start := offset + 2
+	if uint64(start)+uint64(count)*12 > uint64(len(r.tiff)) {
+		return entries
+	}
+	for i := uint32(0); i < count; i++ {
+		raw := r.tiff[start+i*12 : start+i*12+12]
+		typ := r.order.Uint16(raw[2:4])
+		n := r.order.Uint32(raw[4:8])
+		size, ok := exifTypeSizes[typ]
+		if !ok {
+			continue
+		}
+		total := uint64(size) * uint64(n)
+		value := raw[8:12]
+		if total > 4 {
+			at := uint64(r.order.Uint32(raw[8:12]))
+			if at+total > uint64(len(r.tiff)) {
+				continue
+			}
+			value = r.tiff[at : at+total]
+		}
+		entries[r.order.Uint16(raw[0:2])] = exifEntry{typ: typ, count: n, value: value[:total]}
+	}
+	return entries
+}
+
+func (r *exifReader) ascii(e exifEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+// uint reads a SHORT or LONG value.
+func (r *exifReader) uint(e exifEntry) uint32 {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return uint32(r.order.Uint16(e.value))
+	case e.typ == 4 && len(e.value) >= 4:
+		return r.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// coordinate converts degrees, minutes and seconds to signed decimal
+// degrees; the negative reference is "S" or "W".
+func (r *exifReader) coordinate(e exifEntry, ref, negative string) *float64 {
+	if e.typ != 5 || e.count != 3 {
+		return nil
+	}
+	var parts [3]float64
+	for i := range parts {
+		num := r.order.Uint32(e.value[i*8:])
+		den := r.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return nil
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if ref == negative {
+		value = -value
+	}
+	return &value
+}
+
+// exifTime parses an EXIF date like "2006:01:02 15:04:05". Without an
+// offset tag the camera clock is taken to be in the server's time zone.
+func exifTime(value, offset string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	var t time.Time
+	var err error
+	if offset != "" {
+		t, err = time.Parse("2006:01:02 15:04:05-07:00", value+offset)
+	} else {
+		t, err = time.ParseInLocation("2006:01:02 15:04:05", value, time.Local)
+	}
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// deviceName joins make and model unless the model already names the maker.
+func deviceName(make, model string) string {
+	if make == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(make)) {
+		return model
+	}
+	return strings.TrimSpace(make + " " + model)
+}
@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// tiffEntry is an IFD entry for building test EXIF data. Values over four
+// bytes are written after the IFD.
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+// buildIFD encodes entries as a big-endian IFD located at offset.
+func buildIFD(entries []tiffEntry, offset uint32) []byte {
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(entries)))
+	dataAt := offset + 2 + uint32(len(entries))*12 + 4
+	var data []byte
+	for _, e := range entries {
+		out = binary.BigEndian.AppendUint16(out, e.tag)
+		out = binary.BigEndian.AppendUint16(out, e.typ)
+		out = binary.BigEndian.AppendUint32(out, e.count)
+		if len(e.value) > 4 {
+			out = binary.BigEndian.AppendUint32(out, dataAt+uint32(len(data)))
+			data = append(data, e.value...)
+		} else {
+			out = append(out, append(e.value, make([]byte, 4-len(e.value))...)...)
+		}
+	}
+	out = binary.BigEndian.AppendUint32(out, 0)
+	return append(out, data...)
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func longEntry(tag uint16, v uint32) tiffEntry {
+	return tiffEntry{tag: tag, typ: 4, count: 1, value: binary.BigEndian.AppendUint32(nil, v)}
+}
+
+func rationalEntry(tag uint16, values ...uint32) tiffEntry {
+	var b []byte
+	for i := 0; i < len(values); i += 2 {
+		b = binary.BigEndian.AppendUint32(b, values[i])
+		b = binary.BigEndian.AppendUint32(b, values[i+1])
+	}
+	return tiffEntry{tag: tag, typ: 5, count: uint32(len(values) / 2), value: b}
+}
+
+// buildJPEG wraps IFD0 with optional Exif and GPS sub-IFDs in a JPEG APP1
+// segment.
+func buildJPEG(ifd0, exif, gps []tiffEntry) []byte {
+	// Sub-IFD pointers are LONG values stored inline, so the size of IFD0
+	// does not depend on them
+	withPointers := func(exifAt, gpsAt uint32) []tiffEntry {
+		entries := append([]tiffEntry{}, ifd0...)
+		if exif != nil {
+			entries = append(entries, longEntry(exifTagExifIFD, exifAt))
+		}
+		if gps != nil {
+			entries = append(entries, longEntry(exifTagGPSIFD, gpsAt))
+		}
+		return entries
+	}
+	exifAt := 8 + uint32(len(buildIFD(withPointers(0, 0), 8)))
+	gpsAt := exifAt + uint32(len(buildIFD(exif, exifAt)))
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = append(tiff, buildIFD(withPointers(exifAt, gpsAt), 8)...)
+	if exif != nil {
+		tiff = append(tiff, buildIFD(exif, exifAt)...)
+	}
+	if gps != nil {
+		tiff = append(tiff, buildIFD(gps, gpsAt)...)
+	}
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(segment)+2))
+	jpeg = append(jpeg, segment...)
+	return append(jpeg, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestParseEXIF(t *testing.T) {
+	orientation := tiffEntry{tag: exifTagOrientation, typ: 3, count: 1, value: []byte{0, 6}}
+	full := buildJPEG(
+		[]tiffEntry{asciiEntry(exifTagMake, "Apple"), asciiEntry(exifTagModel, "iPhone 13"), orientation},
+		[]tiffEntry{asciiEntry(exifTagDateTimeOriginal, "2024:05:01 09:30:15"), asciiEntry(exifTagOffsetOriginal, "+03:00")},
+		[]tiffEntry{
+			asciiEntry(gpsTagLatitudeRef, "N"), rationalEntry(gpsTagLatitude, 55, 1, 45, 1, 1800, 100),
+			asciiEntry(gpsTagLongitudeRef, "W"), rationalEntry(gpsTagLongitude, 37, 1, 30, 1, 0, 1),
+		},
+	)
+
+	meta := parseEXIF(full)
+	if meta.Device != "Apple iPhone 13" {
+		t.Errorf("expected device %q, got %q", "Apple iPhone 13", meta.Device)
+	}
+	if meta.Orientation != 6 {
+		t.Errorf("expected orientation 6, got %d", meta.Orientation)
+	}
+	if want := time.Date(2024, 5, 1, 6, 30, 15, 0, time.UTC); meta.TakenAt == nil || !meta.TakenAt.Equal(want) {
+		t.Errorf("expected taken at %v, got %v", want, meta.TakenAt)
+	}
+	if meta.Latitude == nil || *meta.Latitude < 55.755 || *meta.Latitude > 55.756 {
+		t.Errorf("expected latitude 55.755, got %v", meta.Latitude)
+	}
+	if meta.Longitude == nil || *meta.Longitude != -37.5 {
+		t.Errorf("expected longitude -37.5, got %v", meta.Longitude)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		device     string
+		hasTakenAt bool
+	}{
+		{name: "Model names the maker", data: buildJPEG([]tiffEntry{asciiEntry(exifTagMake, "Canon"), asciiEntry(exifTagModel, "Canon EOS 80D")}, nil, nil), device: "Canon EOS 80D"},
+		{name: "Fallback to file date", data: buildJPEG([]tiffEntry{asciiEntry(exifTagDateTime, "2024:05:01 09:30:15")}, nil, nil), hasTakenAt: true},
+		{name: "Blank date", data: buildJPEG(nil, []tiffEntry{asciiEntry(exifTagDateTimeOriginal, "0000:00:00 00:00:00")}, nil)},
+		{name: "No EXIF", data: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}},
+		{name: "Not a JPEG", data: []byte("\x89PNG\r\n\x1a\n")},
+		{name: "Truncated", data: full[:40]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := parseEXIF(tt.data)
+			if meta.Device != tt.device {
+				t.Errorf("expected device %q, got %q", tt.device, meta.Device)
+			}
+			if (meta.TakenAt != nil) != tt.hasTakenAt {
+				t.Errorf("expected taken at: %v, got %v", tt.hasTakenAt, meta.TakenAt)
+			}
+		})
+	}
+}
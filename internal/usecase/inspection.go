@@ -1,41 +1,124 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"image/png"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"MVP_checklist/internal/domain"
 	"github.com/google/uuid"
 )
 
+type InspectionConfig struct {
+	Pipeline domain.StagePipeline
+	// IdleTimeout is how long an in-progress inspection may go without
+	// answers before the sweeper marks it abandoned; zero disables it
+	IdleTimeout time.Duration
+	// PhotoRetention is how long photos of abandoned inspections, and photos
+	// removed from answers, are kept
+	PhotoRetention time.Duration
+	// PhotoFreshnessMargin is how long before the inspection started a photo
+	// may have been taken, allowing for camera clock drift
+	PhotoFreshnessMargin time.Duration
+	// StalePhotos decides what happens to photos taken earlier than that
+	StalePhotos StalePhotoPolicy
+	// PhotoMaxDimension is the longest side photos are scaled down to;
+	// zero keeps the full resolution
+	PhotoMaxDimension int
+	// ThumbnailSize is the longest side of photo thumbnails
+	ThumbnailSize int
+	// MinReferenceSimilarity is the similarity to the question's reference
+	// images below which a photo is flagged as possibly the wrong subject
+	MinReferenceSimilarity float64
+	// UploadRetention is how long photo uploads no answer took up are kept
+	UploadRetention time.Duration
+}
+
+// StalePhotoPolicy is how SaveAnswer treats photos taken before the
+// inspection started.
+type StalePhotoPolicy string
+
+const (
+	StalePhotosFlag   StalePhotoPolicy = "flag"   // keep them marked as stale
+	StalePhotosReject StalePhotoPolicy = "reject" // refuse the answer
+	StalePhotosOff    StalePhotoPolicy = "off"    // do not check capture time
+)
+
+// ParseStalePhotoPolicy parses a policy name; empty means flag.
+func ParseStalePhotoPolicy(s string) (StalePhotoPolicy, error) {
+	switch p := StalePhotoPolicy(s); p {
+	case "":
+		return StalePhotosFlag, nil
+	case StalePhotosFlag, StalePhotosReject, StalePhotosOff:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown stale photo policy %q", s)
+}
+
 type InspectionUseCase struct {
 	repo    domain.ChecklistRepository
 	storage domain.FileStorage
+	cfg     InspectionConfig
+
+	refMu         sync.Mutex
+	refSignatures map[string]imageSignature // by reference image key
 }
 
-func NewInspectionUseCase(repo domain.ChecklistRepository, storage domain.FileStorage) *InspectionUseCase {
-	return &InspectionUseCase{repo: repo, storage: storage}
+func NewInspectionUseCase(repo domain.ChecklistRepository, storage domain.FileStorage, cfg InspectionConfig) *InspectionUseCase {
+	return &InspectionUseCase{repo: repo, storage: storage, cfg: cfg, refSignatures: make(map[string]imageSignature)}
 }
 
-func (u *InspectionUseCase) StartInspection(ctx context.Context, role domain.Role, machineSerial, inspectorName string) (*domain.Inspection, []domain.Question, error) {
+func (u *InspectionUseCase) StartInspection(ctx context.Context, role domain.Role, machineSerial string, inspector *domain.Inspector) (*domain.Inspection, []domain.Question, error) {
+	if !inspector.CanInspect(role) {
+		return nil, nil, fmt.Errorf("inspector %s cannot inspect role %s: %w", inspector.Name, role, domain.ErrForbidden)
+	}
+
+	machineSerial = normalizeSerial(machineSerial)
+	if machineSerial == "" {
+		return nil, nil, &domain.ValidationError{Message: "Укажите серийный номер аппарата"}
+	}
+
 	template, err := u.repo.GetTemplateByRole(ctx, role)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get template for role %s: %w", role, err)
 	}
 
+	machine, err := u.repo.EnsureMachine(ctx, machineSerial)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to register machine %s: %w", machineSerial, err)
+	}
+
+	override, err := u.checkStageGate(ctx, machine, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	inspection := &domain.Inspection{
 		ID:            uuid.New(),
 		TemplateID:    template.ID,
-		MachineSerial: machineSerial,
-		InspectorName: inspectorName,
+		MachineID:     machine.ID,
+		MachineSerial: machine.Serial,
+		InspectorID:   &inspector.ID,
+		InspectorName: inspector.Name,
 		Status:        domain.StatusInProgress,
 		StartedAt:     time.Now(),
 	}
+	if override != nil {
+		inspection.StageOverrideID = &override.ID
+	}
 
 	if err := u.repo.CreateInspection(ctx, inspection); err != nil {
 		return nil, nil, fmt.Errorf("failed to create inspection: %w", err)
 	}
+	recordAudit(ctx, u.repo, domain.AuditInspectionStart, domain.AuditEntityInspection, inspection.ID.String(), nil, inspection)
 
 	questions, err := u.repo.GetQuestionsByTemplateID(ctx, template.ID)
 	if err != nil {
@@ -45,35 +128,373 @@ func (u *InspectionUseCase) StartInspection(ctx context.Context, role domain.Rol
 	return inspection, questions, nil
 }
 
-func (u *InspectionUseCase) SaveAnswer(ctx context.Context, inspectionID, questionID uuid.UUID, comment string, photos [][]byte) error {
+// openStatuses are the statuses of inspections the inspector still works on.
+var openStatuses = []domain.InspectionStatus{domain.StatusInProgress, domain.StatusReturned}
+
+// ListOpenInspections returns the inspector's unfinished inspections of the
+// role, including those returned for rework, newest first, with their progress.
+func (u *InspectionUseCase) ListOpenInspections(ctx context.Context, role domain.Role, inspector *domain.Inspector) ([]domain.InspectionProgress, error) {
+	inspections, err := u.repo.ListInspections(ctx, domain.InspectionFilter{
+		Role:        &role,
+		Statuses:    openStatuses,
+		InspectorID: &inspector.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open inspections: %w", err)
+	}
+
+	progress := make([]domain.InspectionProgress, 0, len(inspections))
+	for _, inspection := range inspections {
+		questions, answers, err := u.questionsAndAnswers(ctx, &inspection)
+		if err != nil {
+			return nil, err
+		}
+		visible := visibleQuestions(questions, answers)
+		latest := latestAnswers(answers)
+		answered, rework := 0, 0
+		for _, q := range visible {
+			if a, ok := latest[q.ID]; ok {
+				answered++
+				if a.NeedsRework() {
+					rework++
+				}
+			}
+		}
+		progress = append(progress, domain.InspectionProgress{Inspection: inspection, Answered: answered, Total: len(visible), Rework: rework})
+	}
+	return progress, nil
+}
+
+// FindOpenInspection returns the inspector's unfinished inspection of the
+// machine for the role, or ErrNotFound if there is none.
+func (u *InspectionUseCase) FindOpenInspection(ctx context.Context, role domain.Role, machineSerial string, inspector *domain.Inspector) (*domain.Inspection, error) {
+	machineSerial = normalizeSerial(machineSerial)
+	if machineSerial == "" {
+		// An empty serial would not filter by machine at all
+		return nil, domain.ErrNotFound
+	}
+	inspections, err := u.repo.ListInspections(ctx, domain.InspectionFilter{
+		Role:          &role,
+		Statuses:      openStatuses,
+		InspectorID:   &inspector.ID,
+		MachineSerial: machineSerial,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open inspections: %w", err)
+	}
+	if len(inspections) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return &inspections[0], nil
+}
+
+// ResumeStep returns the step to continue the inspection from: the first
+// visible question without an answer or sent back for rework, or the last
+// one when nothing is left.
+func (u *InspectionUseCase) ResumeStep(ctx context.Context, inspection *domain.Inspection) (int, error) {
+	questions, answers, err := u.questionsAndAnswers(ctx, inspection)
+	if err != nil {
+		return 0, err
+	}
+	return resumeStep(visibleQuestions(questions, answers), answers), nil
+}
+
+// PendingStep returns the first step still waiting for an answer or rework,
+// or 0 when the inspection can be completed.
+func (u *InspectionUseCase) PendingStep(ctx context.Context, inspection *domain.Inspection) (int, error) {
+	questions, answers, err := u.questionsAndAnswers(ctx, inspection)
+	if err != nil {
+		return 0, err
+	}
+	return pendingStep(visibleQuestions(questions, answers), answers), nil
+}
+
+func resumeStep(visible []domain.Question, answers []domain.InspectionAnswer) int {
+	if step := pendingStep(visible, answers); step > 0 {
+		return step
+	}
+	return max(len(visible), 1)
+}
+
+func pendingStep(visible []domain.Question, answers []domain.InspectionAnswer) int {
+	latest := latestAnswers(answers)
+	for i, q := range visible {
+		if a, ok := latest[q.ID]; !ok || a.NeedsRework() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (u *InspectionUseCase) questionsAndAnswers(ctx context.Context, inspection *domain.Inspection) ([]domain.Question, []domain.InspectionAnswer, error) {
+	questions, err := u.repo.GetQuestionsByTemplateID(ctx, inspection.TemplateID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get questions: %w", err)
+	}
+	answers, err := u.repo.GetInspectionAnswers(ctx, inspection.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get answers: %w", err)
+	}
+	return questions, answers, nil
+}
+
+// checkStageGate rejects the inspection unless the previous stages of the
+// machine have passed. An unused admin override lets it through and is
+// returned so the inspection can record it.
+func (u *InspectionUseCase) checkStageGate(ctx context.Context, machine *domain.Machine, role domain.Role) (*domain.StageOverride, error) {
+	prerequisites := u.cfg.Pipeline.Prerequisites(role)
+	if len(prerequisites) == 0 {
+		return nil, nil
+	}
+
+	history, err := u.repo.ListMachineInspections(ctx, machine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine history: %w", err)
+	}
+
+	missing := missingStages(prerequisites, history)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	override, err := u.repo.GetUnusedStageOverride(ctx, machine.ID, role)
+	if err == nil {
+		return override, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get stage override: %w", err)
+	}
+
+	return nil, &domain.StageGateError{
+		MachineSerial: machine.Serial,
+		Role:          role,
+		Missing:       missing,
+	}
+}
+
+// missingStages returns the prerequisite roles whose latest completed
+// inspection is absent or did not pass. Inspections completed before verdicts
+// were introduced have none and count as passed. History must be in
+// chronological order.
+func missingStages(prerequisites []domain.Role, history []domain.MachineInspection) []domain.Role {
+	latest := make(map[domain.Role]domain.Inspection)
+	for _, h := range history {
+		if h.Inspection.Status == domain.StatusCompleted {
+			latest[h.Role] = h.Inspection
+		}
+	}
+
+	var missing []domain.Role
+	for _, role := range prerequisites {
+		inspection, ok := latest[role]
+		if !ok || (inspection.Verdict != domain.VerdictPass && inspection.Verdict != "") {
+			missing = append(missing, role)
+		}
+	}
+	return missing
+}
+
+// AnswerInput is an inspector's answer to one question.
+type AnswerInput struct {
+	QuestionID uuid.UUID
+	Verdict    domain.Verdict // may be empty for questions whose value decides the result
+	Value      *domain.AnswerValue
+	Comment    string
+	KeepPhotos []string    // keys of previously saved photos to keep
+	Uploads    []uuid.UUID // completed uploads of new photos
+}
+
+// SaveAnswer creates or replaces the answer to a question, so submitting a
+// step again or going back to edit it never duplicates the answer. Saved
+// photos not listed in KeepPhotos are dropped from the answer, and the
+// uploads of new photos are discarded once saved. It returns the quality
+// issues of new photos accepted with a warning.
+func (u *InspectionUseCase) SaveAnswer(ctx context.Context, inspectionID uuid.UUID, input AnswerInput) ([]domain.PhotoIssue, error) {
 	inspection, err := u.repo.GetInspectionByID(ctx, inspectionID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := checkOpen(inspection); err != nil {
+		return nil, err
+	}
+
+	questions, err := u.repo.GetQuestionsByTemplateID(ctx, inspection.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questions: %w", err)
+	}
+	idx := slices.IndexFunc(questions, func(q domain.Question) bool { return q.ID == input.QuestionID })
+	if idx < 0 {
+		return nil, fmt.Errorf("question %s: %w", input.QuestionID, domain.ErrNotFound)
+	}
+
+	answers, err := u.repo.GetInspectionAnswers(ctx, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answers: %w", err)
 	}
-	if inspection.Status == domain.StatusCompleted {
-		return fmt.Errorf("inspection already completed")
+	if !slices.ContainsFunc(visibleQuestions(questions, answers), func(q domain.Question) bool { return q.ID == input.QuestionID }) {
+		return nil, &domain.ValidationError{Message: "Этот вопрос не нужно заполнять при текущих ответах"}
 	}
 
-	var photoKeys []string
-	for i, data := range photos {
-		key := fmt.Sprintf("inspections/%s/%s/%d.jpg", inspectionID, questionID, i)
-		uploadedKey, err := u.storage.Upload(ctx, "", key, data)
+	verdict, err := evaluateAnswer(questions[idx], input.Value, input.Verdict)
+	if err != nil {
+		return nil, err
+	}
+
+	questionID := input.QuestionID
+	previous, answered := latestAnswers(answers)[questionID]
+	// After a review only the answers sent back and new questions may change
+	if inspection.Status == domain.StatusReturned && answered && previous.ReworkComment == "" {
+		return nil, &domain.ValidationError{Message: "Ответ принят проверяющим, его нельзя изменить"}
+	}
+	photos := keptPhotos(previous.Photos, input.KeepPhotos)
+	if err := checkPhotoCount(questions[idx], len(photos)+len(input.Uploads), verdict); err != nil {
+		return nil, err
+	}
+	uploads, err := u.uploadedPhotos(ctx, inspectionID, input.Uploads)
+	if err != nil {
+		return nil, err
+	}
+	var issues []domain.PhotoIssue
+	processed := make([]*processedPhoto, len(uploads))
+	for i, upload := range uploads {
+		content, err := u.openUpload(ctx, upload)
+		if err != nil {
+			return nil, err
+		}
+		processed[i], err = processPhoto(content, u.cfg.PhotoMaxDimension, u.cfg.ThumbnailSize)
+		content.Close()
+		if errors.Is(err, errPhotoTooLarge) {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Разрешение фото %d слишком большое, допускается не больше %d Мп", i+1, maxPhotoPixels/1_000_000)}
+		}
+		if err != nil {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Не удалось прочитать фото %d. Загрузите снимок в формате JPEG или PNG", i+1)}
+		}
+		if metadata := &processed[i].Metadata; u.cfg.StalePhotos != StalePhotosOff {
+			metadata.Stale = isStalePhoto(*metadata, inspection.StartedAt, u.cfg.PhotoFreshnessMargin)
+			if metadata.Stale && u.cfg.StalePhotos == StalePhotosReject {
+				return nil, &domain.ValidationError{Message: fmt.Sprintf("Фото %d снято %s, до начала проверки. Сделайте новый снимок", i+1, metadata.TakenAt.Format("02.01.2006 15:04"))}
+			}
+		}
+		cfg := questions[idx].Config.PhotoQuality
+		processed[i].Quality.Issues = photoQualityIssues(processed[i].Quality, cfg)
+		if len(processed[i].Quality.Issues) > 0 {
+			if cfg.Reject {
+				return nil, &domain.ValidationError{Message: fmt.Sprintf("Фото %d: %s. Переснимите фото", i+1, photoIssuesText(processed[i].Quality.Issues))}
+			}
+			for _, issue := range processed[i].Quality.Issues {
+				if !slices.Contains(issues, issue) {
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+	var references []imageSignature
+	if len(processed) > 0 {
+		references = u.referenceSignatures(ctx, questions[idx].ReferenceImages)
+	}
+	kept := len(photos)
+	for i, p := range processed {
+		// The original is copied from the upload as is
+		original, err := u.openUpload(ctx, uploads[i])
 		if err != nil {
-			return fmt.Errorf("failed to upload photo %d: %w", i, err)
+			u.deletePhotos(ctx, photos[kept:])
+			return nil, err
 		}
-		photoKeys = append(photoKeys, uploadedKey)
+		photo, err := u.uploadPhoto(ctx, fmt.Sprintf("inspections/%s/%s/%s", inspectionID, questionID, uuid.New()), p, original, uploads[i].Size)
+		original.Close()
+		if err != nil {
+			u.deletePhotos(ctx, append(photos[kept:], photo))
+			return nil, fmt.Errorf("failed to upload photo %d: %w", i, err)
+		}
+		if len(references) > 0 {
+			similarity := referenceSimilarity(p.Signature, references)
+			photo.ReferenceSimilarity = &similarity
+			photo.WrongSubject = similarity < u.cfg.MinReferenceSimilarity
+		}
+		photos = append(photos, photo)
 	}
 
+	now := time.Now()
 	answer := &domain.InspectionAnswer{
-		ID:           uuid.New(), // In a real scenario, we might want to find existing answer to update
+		ID:           uuid.New(),
 		InspectionID: inspectionID,
 		QuestionID:   questionID,
-		Comment:      comment,
-		Photos:       photoKeys,
-		CreatedAt:    time.Now(),
+		Verdict:      verdict,
+		Value:        input.Value,
+		Comment:      input.Comment,
+		Photos:       photos,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := u.repo.SaveAnswer(ctx, answer, now.Add(u.cfg.PhotoRetention)); err != nil {
+		// The new photos belong to no answer now
+		u.deletePhotos(ctx, photos[kept:])
+		return nil, err
+	}
+	var before any
+	if answered {
+		before = previous
+	}
+	recordAudit(ctx, u.repo, domain.AuditInspectionAnswer, domain.AuditEntityInspection, inspectionID.String(), before, answer)
+	u.flagDuplicatePhotos(ctx, inspection, photos[kept:])
+	if len(input.Uploads) > 0 {
+		if err := u.repo.DeletePhotoUploads(ctx, input.Uploads); err != nil {
+			log.Printf("Failed to delete uploads of saved answer: %v", err)
+		}
+	}
+	return issues, nil
+}
+
+// keptPhotos returns the saved photos the inspector chose to keep, with
+// their metadata. Keys that do not belong to the saved answer are ignored.
+func keptPhotos(saved []domain.AnswerPhoto, keep []string) []domain.AnswerPhoto {
+	var kept []domain.AnswerPhoto
+	for _, key := range keep {
+		i := slices.IndexFunc(saved, func(p domain.AnswerPhoto) bool { return p.Key == key })
+		if i >= 0 && !slices.ContainsFunc(kept, func(p domain.AnswerPhoto) bool { return p.Key == key }) {
+			kept = append(kept, saved[i])
+		}
+	}
+	return kept
+}
+
+// isStalePhoto reports whether a photo was taken more than margin before the
+// inspection started. Photos without a capture time are not stale.
+func isStalePhoto(meta domain.PhotoMetadata, startedAt time.Time, margin time.Duration) bool {
+	return meta.TakenAt != nil && meta.TakenAt.Before(startedAt.Add(-margin))
+}
+
+// GetAnswer returns the saved answer to a question for editing, or nil if
+// there is none, along with thumbnail URLs of its photos keyed by storage key.
+// A verdict the value alone decides is cleared, so that changing the value
+// re-evaluates it.
+func (u *InspectionUseCase) GetAnswer(ctx context.Context, inspectionID uuid.UUID, q domain.Question) (*domain.InspectionAnswer, map[string]string, error) {
+	answers, err := u.repo.GetInspectionAnswers(ctx, inspectionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get answers: %w", err)
+	}
+	answer, ok := latestAnswers(answers)[q.ID]
+	if !ok {
+		return nil, nil, nil
+	}
+	if derived, err := evaluateAnswer(q, answer.Value, ""); err == nil && derived == answer.Verdict {
+		answer.Verdict = ""
 	}
 
-	return u.repo.SaveAnswer(ctx, answer)
+	urls := make(map[string]string)
+	for _, p := range answer.Photos {
+		key := p.ThumbnailKey
+		if key == "" {
+			key = p.Key
+		}
+		url, err := u.storage.GetURL(ctx, "", key)
+		if err != nil {
+			url = key
+		}
+		urls[p.Key] = url
+	}
+	return &answer, urls, nil
 }
 
 func (u *InspectionUseCase) GetInspectionByID(ctx context.Context, id uuid.UUID) (*domain.Inspection, error) {
@@ -118,6 +539,199 @@ func (u *InspectionUseCase) GetQuestionsByTemplateID(ctx context.Context, templa
 	return nil, questions, nil
 }
 
-func (u *InspectionUseCase) CompleteInspection(ctx context.Context, inspectionID uuid.UUID) error {
-	return u.repo.CompleteInspection(ctx, inspectionID)
+// VisibleQuestions returns the questions of the inspection that apply given
+// the answers saved so far, in order. Step numbers in the public checklist
+// index into this list.
+func (u *InspectionUseCase) VisibleQuestions(ctx context.Context, inspection *domain.Inspection) ([]domain.Question, error) {
+	_, questions, err := u.GetQuestionsByTemplateID(ctx, inspection.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := u.repo.GetInspectionAnswers(ctx, inspection.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answers: %w", err)
+	}
+	return visibleQuestions(questions, answers), nil
+}
+
+// CheckCompletion reports why the inspection cannot be completed yet, or
+// nil when it is ready to be signed.
+func (u *InspectionUseCase) CheckCompletion(ctx context.Context, inspection *domain.Inspection) error {
+	if err := checkOpen(inspection); err != nil {
+		return err
+	}
+	_, err := u.completionVerdict(ctx, inspection)
+	return err
+}
+
+// CompleteInspection signs, completes and seals the inspection. The signature is a
+// PNG image drawn by the inspector; signedBy names them. The completed
+// inspection awaits review.
+func (u *InspectionUseCase) CompleteInspection(ctx context.Context, inspectionID uuid.UUID, signature []byte, signedBy string) error {
+	inspection, err := u.repo.GetInspectionByID(ctx, inspectionID)
+	if err != nil {
+		return err
+	}
+	if err := checkOpen(inspection); err != nil {
+		return err
+	}
+	verdict, err := u.completionVerdict(ctx, inspection)
+	if err != nil {
+		return err
+	}
+	if err := validateSignature(signature); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("inspections/%s/signature-%s.png", inspectionID, uuid.New())
+	uploadedKey, err := u.storage.Upload(ctx, "", key, bytes.NewReader(signature), int64(len(signature)), "image/png")
+	if err != nil {
+		return fmt.Errorf("failed to upload signature: %w", err)
+	}
+	signed := domain.Signature{Key: uploadedKey, SignedBy: signedBy, SignedAt: time.Now()}
+	if err := u.repo.CompleteInspection(ctx, inspectionID, verdict, signed); err != nil {
+		// The signature belongs to no inspection now
+		if deleteErr := u.storage.Delete(ctx, "", uploadedKey); deleteErr != nil {
+			log.Printf("Failed to delete signature %s: %v", uploadedKey, deleteErr)
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			return &domain.ValidationError{Message: "Проверка уже закрыта"}
+		}
+		return fmt.Errorf("failed to complete inspection: %w", err)
+	}
+	after := *inspection
+	after.Status, after.Verdict, after.FinishedAt = domain.StatusCompleted, verdict, &signed.SignedAt
+	after.SignatureKey, after.SignedBy, after.SignedAt = signed.Key, signed.SignedBy, &signed.SignedAt
+	recordAudit(ctx, u.repo, domain.AuditInspectionComplete, domain.AuditEntityInspection, inspectionID.String(), inspection, after)
+
+	// A missing seal is reported by integrity verification and can be added later
+	if _, err := sealInspection(ctx, u.repo, u.storage, inspectionID); err != nil {
+		log.Printf("Integrity: failed to seal inspection %s: %v", inspectionID, err)
+	}
+	return nil
+}
+
+// completionVerdict requires an answer to every visible required question
+// and a new answer to every one sent back for rework, and returns the
+// overall verdict. Answers to questions hidden by a condition do not affect it.
+func (u *InspectionUseCase) completionVerdict(ctx context.Context, inspection *domain.Inspection) (domain.Verdict, error) {
+	questions, err := u.repo.GetQuestionsByTemplateID(ctx, inspection.TemplateID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get questions: %w", err)
+	}
+	answers, err := u.repo.GetInspectionAnswers(ctx, inspection.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get answers: %w", err)
+	}
+
+	latest := latestAnswers(answers)
+	var counted []domain.InspectionAnswer
+	var missing, rework []string
+	for step, q := range visibleQuestions(questions, answers) {
+		answer, ok := latest[q.ID]
+		if !ok {
+			if q.IsRequired {
+				missing = append(missing, strconv.Itoa(step+1))
+			}
+			continue
+		}
+		if answer.NeedsRework() {
+			rework = append(rework, strconv.Itoa(step+1))
+		}
+		counted = append(counted, answer)
+	}
+	if len(missing) > 0 {
+		return "", &domain.ValidationError{Message: "Не отвечены вопросы: " + strings.Join(missing, ", ")}
+	}
+	if len(rework) > 0 {
+		return "", &domain.ValidationError{Message: "Не исправлены вопросы: " + strings.Join(rework, ", ")}
+	}
+	return overallVerdict(counted), nil
+}
+
+const (
+	// maxSignatureSize limits the signature image accepted on completion.
+	maxSignatureSize = 1 << 20
+	// maxSignatureWidth and maxSignatureHeight bound its dimensions to the
+	// drawing buffer of the signature pad, which the page caps likewise
+	maxSignatureWidth  = 4096
+	maxSignatureHeight = 1024
+)
+
+// validateSignature accepts a PNG image with at least one drawn pixel.
+func validateSignature(data []byte) error {
+	if len(data) == 0 {
+		return &domain.ValidationError{Message: "Поставьте подпись"}
+	}
+	if len(data) > maxSignatureSize {
+		return &domain.ValidationError{Message: "Подпись слишком большая"}
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return &domain.ValidationError{Message: "Подпись должна быть изображением PNG"}
+	}
+	if config.Width > maxSignatureWidth || config.Height > maxSignatureHeight {
+		return &domain.ValidationError{Message: "Подпись слишком большая"}
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return &domain.ValidationError{Message: "Подпись должна быть изображением PNG"}
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+				return nil
+			}
+		}
+	}
+	return &domain.ValidationError{Message: "Поставьте подпись"}
+}
+
+// CancelInspection closes an open inspection without a verdict.
+// The reason is required; cancelledBy names the inspector or admin user.
+func (u *InspectionUseCase) CancelInspection(ctx context.Context, inspectionID uuid.UUID, reason, cancelledBy string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return &domain.ValidationError{Message: "Укажите причину отмены"}
+	}
+
+	inspection, err := u.repo.GetInspectionByID(ctx, inspectionID)
+	if err != nil {
+		return err
+	}
+	if err := checkOpen(inspection); err != nil {
+		return err
+	}
+
+	if err := u.repo.CancelInspection(ctx, inspectionID, reason, cancelledBy); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return &domain.ValidationError{Message: "Проверка уже закрыта"}
+		}
+		return fmt.Errorf("failed to cancel inspection: %w", err)
+	}
+	after := *inspection
+	after.Status, after.CancelReason, after.CancelledBy = domain.StatusCancelled, reason, cancelledBy
+	recordAudit(ctx, u.repo, domain.AuditInspectionCancel, domain.AuditEntityInspection, inspectionID.String(), inspection, after)
+	return nil
+}
+
+// checkOpen rejects changes to an inspection that is neither in progress
+// nor returned for rework.
+func checkOpen(inspection *domain.Inspection) error {
+	if !slices.Contains(openStatuses, inspection.Status) {
+		return &domain.ValidationError{Message: "Проверка уже закрыта: " + strings.ToLower(inspection.Status.Title())}
+	}
+	return nil
+}
+
+// overallVerdict fails the inspection if any answer failed; answers marked
+// as not applicable do not affect the result.
+func overallVerdict(answers []domain.InspectionAnswer) domain.Verdict {
+	for _, a := range answers {
+		if a.Verdict == domain.VerdictFail {
+			return domain.VerdictFail
+		}
+	}
+	return domain.VerdictPass
 }
@@ -0,0 +1,376 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestOverallVerdict(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []domain.Verdict
+		expected domain.Verdict
+	}{
+		{
+			name:     "All passed",
+			verdicts: []domain.Verdict{domain.VerdictPass, domain.VerdictPass},
+			expected: domain.VerdictPass,
+		},
+		{
+			name:     "One failed",
+			verdicts: []domain.Verdict{domain.VerdictPass, domain.VerdictFail, domain.VerdictPass},
+			expected: domain.VerdictFail,
+		},
+		{
+			name:     "Not applicable is ignored",
+			verdicts: []domain.Verdict{domain.VerdictNotApplicable, domain.VerdictPass},
+			expected: domain.VerdictPass,
+		},
+		{
+			name:     "No answers",
+			verdicts: nil,
+			expected: domain.VerdictPass,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var answers []domain.InspectionAnswer
+			for _, v := range tt.verdicts {
+				answers = append(answers, domain.InspectionAnswer{Verdict: v})
+			}
+
+			if result := overallVerdict(answers); result != tt.expected {
+				t.Errorf("Expected verdict=%s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestKeptPhotos(t *testing.T) {
+	taken := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	a := domain.AnswerPhoto{Key: "inspections/1/a.jpg", PhotoMetadata: domain.PhotoMetadata{TakenAt: &taken, Device: "Pixel 7"}}
+	b := domain.AnswerPhoto{Key: "inspections/1/b.jpg"}
+	saved := []domain.AnswerPhoto{a, b}
+
+	tests := []struct {
+		name     string
+		keep     []string
+		expected []domain.AnswerPhoto
+	}{
+		{name: "Keep all", keep: []string{a.Key, b.Key}, expected: saved},
+		{name: "Remove one", keep: []string{"inspections/1/b.jpg"}, expected: []domain.AnswerPhoto{b}},
+		{name: "Remove all", keep: nil, expected: nil},
+		{name: "Foreign key is ignored", keep: []string{"inspections/2/a.jpg", "inspections/1/a.jpg"}, expected: []domain.AnswerPhoto{a}},
+		{name: "Duplicate key is kept once", keep: []string{"inspections/1/a.jpg", "inspections/1/a.jpg"}, expected: []domain.AnswerPhoto{a}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keptPhotos(saved, tt.keep)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsStalePhoto(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) domain.PhotoMetadata {
+		taken := started.Add(d)
+		return domain.PhotoMetadata{TakenAt: &taken}
+	}
+
+	tests := []struct {
+		name     string
+		meta     domain.PhotoMetadata
+		margin   time.Duration
+		expected bool
+	}{
+		{name: "Taken during inspection", meta: at(5 * time.Minute), margin: 10 * time.Minute, expected: false},
+		{name: "Taken within margin", meta: at(-5 * time.Minute), margin: 10 * time.Minute, expected: false},
+		{name: "Taken before margin", meta: at(-time.Hour), margin: 10 * time.Minute, expected: true},
+		{name: "No margin", meta: at(-time.Second), margin: 0, expected: true},
+		{name: "No capture time", meta: domain.PhotoMetadata{}, margin: 0, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStalePhoto(tt.meta, started, tt.margin); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResumeStep(t *testing.T) {
+	q1 := domain.Question{ID: uuid.New(), Order: 1}
+	q2 := domain.Question{ID: uuid.New(), Order: 2}
+	q3 := domain.Question{ID: uuid.New(), Order: 3}
+	answer := func(q domain.Question) domain.InspectionAnswer {
+		return domain.InspectionAnswer{QuestionID: q.ID, Verdict: domain.VerdictPass}
+	}
+	rework := func(q domain.Question) domain.InspectionAnswer {
+		requested := time.Now()
+		return domain.InspectionAnswer{QuestionID: q.ID, Verdict: domain.VerdictPass, ReworkComment: "redo", ReworkRequestedAt: &requested}
+	}
+
+	tests := []struct {
+		name     string
+		visible  []domain.Question
+		answers  []domain.InspectionAnswer
+		expected int
+	}{
+		{name: "Nothing answered", visible: []domain.Question{q1, q2, q3}, expected: 1},
+		{name: "First unanswered", visible: []domain.Question{q1, q2, q3}, answers: []domain.InspectionAnswer{answer(q1)}, expected: 2},
+		{name: "Gap in answers", visible: []domain.Question{q1, q2, q3}, answers: []domain.InspectionAnswer{answer(q1), answer(q3)}, expected: 2},
+		{name: "All answered", visible: []domain.Question{q1, q2, q3}, answers: []domain.InspectionAnswer{answer(q1), answer(q2), answer(q3)}, expected: 3},
+		{name: "Hidden question is skipped", visible: []domain.Question{q1, q3}, answers: []domain.InspectionAnswer{answer(q1)}, expected: 2},
+		{name: "Answer sent back", visible: []domain.Question{q1, q2, q3}, answers: []domain.InspectionAnswer{answer(q1), rework(q2), answer(q3)}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resumeStep(tt.visible, tt.answers); got != tt.expected {
+				t.Errorf("expected step %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckOpen(t *testing.T) {
+	tests := []struct {
+		status    domain.InspectionStatus
+		wantError bool
+	}{
+		{status: domain.StatusInProgress, wantError: false},
+		{status: domain.StatusReturned, wantError: false},
+		{status: domain.StatusCompleted, wantError: true},
+		{status: domain.StatusCancelled, wantError: true},
+		{status: domain.StatusAbandoned, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			err := checkOpen(&domain.Inspection{Status: tt.status})
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestStartInspectionWithoutSerial(t *testing.T) {
+	inspector := &domain.Inspector{Name: "Иванов", Roles: []domain.Role{domain.RoleOTK}, IsActive: true}
+	uc := NewInspectionUseCase(nil, nil, InspectionConfig{})
+
+	for _, serial := range []string{"", "   "} {
+		t.Run(strconv.Quote(serial), func(t *testing.T) {
+			_, _, err := uc.StartInspection(context.Background(), domain.RoleOTK, serial, inspector)
+			var validationErr *domain.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+			if _, err := uc.FindOpenInspection(context.Background(), domain.RoleOTK, serial, inspector); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("expected no open inspection, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		return buf.Bytes()
+	}
+	blank := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	signed := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	signed.Set(5, 5, color.Black)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantError bool
+	}{
+		{name: "Signed", data: encode(signed), wantError: false},
+		{name: "Empty", data: nil, wantError: true},
+		{name: "Blank canvas", data: encode(blank), wantError: true},
+		{name: "Not a PNG", data: []byte("signature"), wantError: true},
+		{name: "Too large", data: make([]byte, maxSignatureSize+1), wantError: true},
+		{name: "Too many pixels", data: func() []byte {
+			// A small PNG whose header claims 20000x20000 pixels
+			data := encode(signed)
+			binary.BigEndian.PutUint32(data[16:20], 20000)
+			binary.BigEndian.PutUint32(data[20:24], 20000)
+			binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+			return data
+		}(), wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSignature(tt.data)
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+// uploadRepo keeps one inspection and its photo uploads in memory. A non-nil
+// saveErr makes saving answers fail.
+type uploadRepo struct {
+	domain.ChecklistRepository
+	mu         sync.Mutex
+	inspection domain.Inspection
+	questions  []domain.Question
+	uploads    map[uuid.UUID]domain.PhotoUpload
+	chunks     map[uuid.UUID][]domain.PhotoUploadChunk
+	answers    []domain.InspectionAnswer
+	saveErr    error
+}
+
+func newUploadRepo(questions ...domain.Question) *uploadRepo {
+	return &uploadRepo{
+		inspection: domain.Inspection{ID: uuid.New(), TemplateID: uuid.New(), Status: domain.StatusInProgress, StartedAt: time.Now()},
+		questions:  questions,
+		uploads:    make(map[uuid.UUID]domain.PhotoUpload),
+		chunks:     make(map[uuid.UUID][]domain.PhotoUploadChunk),
+	}
+}
+
+func (r *uploadRepo) GetInspectionByID(ctx context.Context, id uuid.UUID) (*domain.Inspection, error) {
+	if id != r.inspection.ID {
+		return nil, domain.ErrNotFound
+	}
+	inspection := r.inspection
+	return &inspection, nil
+}
+
+func (r *uploadRepo) GetQuestionsByTemplateID(ctx context.Context, templateID uuid.UUID) ([]domain.Question, error) {
+	return r.questions, nil
+}
+
+func (r *uploadRepo) GetInspectionAnswers(ctx context.Context, inspectionID uuid.UUID) ([]domain.InspectionAnswer, error) {
+	return r.answers, nil
+}
+
+func (r *uploadRepo) CreatePhotoUpload(ctx context.Context, upload *domain.PhotoUpload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads[upload.ID] = *upload
+	return nil
+}
+
+func (r *uploadRepo) GetPhotoUpload(ctx context.Context, id uuid.UUID) (*domain.PhotoUpload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &upload, nil
+}
+
+// AppendPhotoUpload only moves the upload on from the chunk's offset, as the
+// database does.
+func (r *uploadRepo) AppendPhotoUpload(ctx context.Context, id uuid.UUID, chunk domain.PhotoUploadChunk) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok || upload.Offset != chunk.Offset || upload.Offset+chunk.Size > upload.Size {
+		return 0, domain.ErrNotFound
+	}
+	upload.Offset += chunk.Size
+	r.uploads[id] = upload
+	r.chunks[id] = append(r.chunks[id], chunk)
+	return upload.Offset, nil
+}
+
+func (r *uploadRepo) ListPhotoUploadChunks(ctx context.Context, id uuid.UUID) ([]domain.PhotoUploadChunk, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chunks[id], nil
+}
+
+func (r *uploadRepo) SaveAnswer(ctx context.Context, answer *domain.InspectionAnswer, cleanupAfter time.Time) error {
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	r.answers = append(r.answers, *answer)
+	return nil
+}
+
+func (r *uploadRepo) DeletePhotoUploads(ctx context.Context, ids []uuid.UUID) error {
+	return nil
+}
+
+func (r *uploadRepo) CreateAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	return nil
+}
+
+// addUpload stores data as a complete upload to the inspection.
+func (r *uploadRepo) addUpload(t *testing.T, uc *InspectionUseCase, data []byte) uuid.UUID {
+	t.Helper()
+	upload, err := uc.CreateUpload(context.Background(), &r.inspection, int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	if _, err := uc.AppendUpload(context.Background(), r.inspection.ID, upload.ID, 0, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("failed to append to upload: %v", err)
+	}
+	return upload.ID
+}
+
+func TestSaveAnswerPhotos(t *testing.T) {
+	photo := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for i := range photo.Pix {
+		photo.Pix[i] = 180
+	}
+	data := encodeTestImage(t, photo, "jpeg")
+	question := domain.Question{ID: uuid.New(), Type: domain.QuestionPhoto, Order: 1, Text: "Фото", MinPhotos: 1, MaxPhotos: 3}
+
+	tests := []struct {
+		name    string
+		saveErr error
+		stored  int // photo files left in storage besides the upload chunk
+	}{
+		{name: "Saved", stored: 3},
+		{name: "Answer not saved", saveErr: errors.New("connection refused"), stored: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newUploadRepo(question)
+			repo.saveErr = tt.saveErr
+			storage := newMemStorage()
+			uc := NewInspectionUseCase(repo, storage, InspectionConfig{})
+			uploadID := repo.addUpload(t, uc, data)
+
+			_, err := uc.SaveAnswer(context.Background(), repo.inspection.ID, AnswerInput{QuestionID: question.ID, Verdict: domain.VerdictPass, Uploads: []uuid.UUID{uploadID}})
+			if (err != nil) != (tt.saveErr != nil) {
+				t.Fatalf("expected error: %v, got %v", tt.saveErr != nil, err)
+			}
+			if got := len(storage.objects) - 1; got != tt.stored {
+				t.Errorf("expected %d photo files stored, got %d", tt.stored, got)
+			}
+		})
+	}
+}
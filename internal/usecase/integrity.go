@@ -0,0 +1,324 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+type IntegrityUseCase struct {
+	repo    domain.ChecklistRepository
+	storage domain.FileStorage
+}
+
+func NewIntegrityUseCase(repo domain.ChecklistRepository, storage domain.FileStorage) *IntegrityUseCase {
+	return &IntegrityUseCase{repo: repo, storage: storage}
+}
+
+// sealedRecord is the canonical form of a completed inspection that its seal
+// hashes. Review fields are left out: approval and rework remarks are added
+// after completion, and a returned inspection is sealed again when completed.
+type sealedRecord struct {
+	ID              uuid.UUID
+	TemplateID      uuid.UUID
+	MachineID       uuid.UUID
+	MachineSerial   string
+	InspectorID     *uuid.UUID
+	InspectorName   string
+	StageOverrideID *uuid.UUID
+	Verdict         domain.Verdict
+	StartedAt       string
+	FinishedAt      string
+	Signature       sealedFile
+	SignedBy        string
+	SignedAt        string
+	Answers         []sealedAnswer // by question ID
+}
+
+type sealedAnswer struct {
+	ID         uuid.UUID
+	QuestionID uuid.UUID
+	Verdict    domain.Verdict
+	Value      *domain.AnswerValue
+	Comment    string
+	Photos     []sealedFile
+	CreatedAt  string
+	UpdatedAt  string
+}
+
+// sealedFile is a stored file with the SHA-256 of its content. Original and
+// metadata are omitted for photos that have none, so records sealed before
+// they were kept hash the same. Thumbnails are derived and not sealed.
+type sealedFile struct {
+	Key      string
+	SHA256   string
+	Original *sealedFile     `json:",omitempty"` // photo as uploaded
+	Metadata *sealedMetadata `json:",omitempty"`
+}
+
+type sealedMetadata struct {
+	TakenAt     string   `json:",omitempty"`
+	Device      string   `json:",omitempty"`
+	Orientation int      `json:",omitempty"`
+	Latitude    *float64 `json:",omitempty"`
+	Longitude   *float64 `json:",omitempty"`
+	Stale       bool     `json:",omitempty"`
+}
+
+// canonicalRecord serializes the inspection and its answers for sealing.
+// fileHashes maps storage keys to content hashes. The result does not
+// depend on the order of answers or the time zone of timestamps.
+func canonicalRecord(inspection *domain.Inspection, answers []domain.InspectionAnswer, fileHashes map[string]string) ([]byte, error) {
+	record := sealedRecord{
+		ID:              inspection.ID,
+		TemplateID:      inspection.TemplateID,
+		MachineID:       inspection.MachineID,
+		MachineSerial:   inspection.MachineSerial,
+		InspectorID:     inspection.InspectorID,
+		InspectorName:   inspection.InspectorName,
+		StageOverrideID: inspection.StageOverrideID,
+		Verdict:         inspection.Verdict,
+		StartedAt:       canonicalTime(&inspection.StartedAt),
+		FinishedAt:      canonicalTime(inspection.FinishedAt),
+		SignedBy:        inspection.SignedBy,
+		SignedAt:        canonicalTime(inspection.SignedAt),
+		Answers:         make([]sealedAnswer, 0, len(answers)),
+	}
+	if inspection.SignatureKey != "" {
+		record.Signature = sealedFile{Key: inspection.SignatureKey, SHA256: fileHashes[inspection.SignatureKey]}
+	}
+	for _, a := range answers {
+		answer := sealedAnswer{
+			ID:         a.ID,
+			QuestionID: a.QuestionID,
+			Verdict:    a.Verdict,
+			Value:      a.Value,
+			Comment:    a.Comment,
+			Photos:     make([]sealedFile, 0, len(a.Photos)),
+			CreatedAt:  canonicalTime(&a.CreatedAt),
+			UpdatedAt:  canonicalTime(&a.UpdatedAt),
+		}
+		for _, p := range a.Photos {
+			answer.Photos = append(answer.Photos, sealedPhoto(p, fileHashes))
+		}
+		record.Answers = append(record.Answers, answer)
+	}
+	sort.Slice(record.Answers, func(i, j int) bool {
+		return record.Answers[i].QuestionID.String() < record.Answers[j].QuestionID.String()
+	})
+	return json.Marshal(record)
+}
+
+func sealedPhoto(p domain.AnswerPhoto, fileHashes map[string]string) sealedFile {
+	file := sealedFile{Key: p.Key, SHA256: fileHashes[p.Key]}
+	if p.OriginalKey != "" {
+		file.Original = &sealedFile{Key: p.OriginalKey, SHA256: fileHashes[p.OriginalKey]}
+	}
+	if p.PhotoMetadata != (domain.PhotoMetadata{}) {
+		file.Metadata = &sealedMetadata{
+			TakenAt:     canonicalTime(p.TakenAt),
+			Device:      p.Device,
+			Orientation: p.Orientation,
+			Latitude:    p.Latitude,
+			Longitude:   p.Longitude,
+			Stale:       p.Stale,
+		}
+	}
+	return file
+}
+
+func canonicalTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// contentHash reads the inspection as stored and hashes its canonical
+// record, downloading the signature and photos to hash their content.
+func contentHash(ctx context.Context, repo domain.ChecklistRepository, storage domain.FileStorage, inspection *domain.Inspection) (string, error) {
+	answers, err := repo.GetInspectionAnswers(ctx, inspection.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get answers: %w", err)
+	}
+
+	keys := make([]string, 0)
+	if inspection.SignatureKey != "" {
+		keys = append(keys, inspection.SignatureKey)
+	}
+	for _, a := range answers {
+		for _, p := range a.Photos {
+			keys = append(keys, p.Key)
+			if p.OriginalKey != "" {
+				keys = append(keys, p.OriginalKey)
+			}
+		}
+	}
+	fileHashes := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sum, err := fileHash(ctx, storage, key)
+		if err != nil {
+			return "", err
+		}
+		fileHashes[key] = sum
+	}
+
+	record, err := canonicalRecord(inspection, answers, fileHashes)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode inspection: %w", err)
+	}
+	sum := sha256.Sum256(record)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fileHash is the hex SHA-256 of a stored file, read as a stream.
+func fileHash(ctx context.Context, storage domain.FileStorage, key string) (string, error) {
+	file, err := storage.Open(ctx, "", key)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sealInspection appends a seal over the inspection as stored. It is read
+// back after completion so that the hash covers the timestamps exactly as
+// the database keeps them.
+func sealInspection(ctx context.Context, repo domain.ChecklistRepository, storage domain.FileStorage, inspectionID uuid.UUID) (*domain.InspectionSeal, error) {
+	inspection, err := repo.GetInspectionByID(ctx, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inspection: %w", err)
+	}
+	hash, err := contentHash(ctx, repo, storage, inspection)
+	if err != nil {
+		return nil, err
+	}
+	return repo.SealInspection(ctx, inspectionID, hash)
+}
+
+// SealInspection seals a completed inspection that has no seal yet, such as
+// one completed while sealing failed.
+func (u *IntegrityUseCase) SealInspection(ctx context.Context, inspectionID uuid.UUID) (*domain.InspectionSeal, error) {
+	inspection, err := u.repo.GetInspectionByID(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if inspection.Status != domain.StatusCompleted {
+		return nil, &domain.ValidationError{Message: "Запечатать можно только завершенную проверку"}
+	}
+	if _, err := u.repo.GetInspectionSeal(ctx, inspectionID); !errors.Is(err, domain.ErrNotFound) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get seal: %w", err)
+		}
+		return nil, &domain.ValidationError{Message: "Проверка уже запечатана"}
+	}
+	return sealInspection(ctx, u.repo, u.storage, inspectionID)
+}
+
+// Verify checks every link of the seal chain and recomputes the records of
+// completed inspections against their latest seals. With an inspection ID
+// only that inspection's record is recomputed; the chain is always checked
+// in full.
+func (u *IntegrityUseCase) Verify(ctx context.Context, inspectionID *uuid.UUID) (*domain.IntegrityReport, error) {
+	return u.verify(ctx, inspectionID, true)
+}
+
+// VerifyChain is Verify without recomputing every record, which reads all
+// photos from storage: without an inspection ID it only checks the chain and
+// that completed inspections are sealed.
+func (u *IntegrityUseCase) VerifyChain(ctx context.Context, inspectionID *uuid.UUID) (*domain.IntegrityReport, error) {
+	return u.verify(ctx, inspectionID, false)
+}
+
+func (u *IntegrityUseCase) verify(ctx context.Context, inspectionID *uuid.UUID, recomputeAll bool) (*domain.IntegrityReport, error) {
+	seals, err := u.repo.ListInspectionSeals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seals: %w", err)
+	}
+	report := &domain.IntegrityReport{Seals: len(seals), Problems: verifyChain(seals), CheckedAt: time.Now()}
+
+	latest := make(map[uuid.UUID]domain.InspectionSeal, len(seals))
+	for _, s := range seals {
+		latest[s.InspectionID] = s
+	}
+
+	var inspections []domain.Inspection
+	if inspectionID != nil {
+		inspection, err := u.repo.GetInspectionByID(ctx, *inspectionID)
+		if err != nil {
+			return nil, err
+		}
+		if inspection.Status == domain.StatusCompleted {
+			inspections = append(inspections, *inspection)
+		}
+	} else {
+		inspections, err = u.repo.ListInspections(ctx, domain.InspectionFilter{Statuses: []domain.InspectionStatus{domain.StatusCompleted}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inspections: %w", err)
+		}
+	}
+
+	for i := range inspections {
+		inspection := &inspections[i]
+		problem := domain.IntegrityProblem{InspectionID: inspection.ID, MachineSerial: inspection.MachineSerial}
+		seal, ok := latest[inspection.ID]
+		if !ok {
+			problem.Message = "Завершенная проверка не запечатана"
+			report.Problems = append(report.Problems, problem)
+			continue
+		}
+		problem.Sequence = seal.Sequence
+		if inspectionID == nil && !recomputeAll {
+			continue
+		}
+
+		report.Inspections++
+		hash, err := contentHash(ctx, u.repo, u.storage, inspection)
+		switch {
+		case err != nil:
+			problem.Message = "Не удалось прочитать запись: " + err.Error()
+		case hash != seal.ContentHash:
+			problem.Message = "Запись проверки изменена после завершения"
+		default:
+			continue
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+	return report, nil
+}
+
+// verifyChain checks that seals are numbered without gaps, each links to the
+// previous one and each hash matches its fields.
+func verifyChain(seals []domain.InspectionSeal) []domain.IntegrityProblem {
+	var problems []domain.IntegrityProblem
+	prevHash := ""
+	for i, s := range seals {
+		problem := domain.IntegrityProblem{Sequence: s.Sequence, InspectionID: s.InspectionID}
+		switch {
+		case s.Sequence != int64(i+1):
+			problem.Message = fmt.Sprintf("Пропуск в цепочке: ожидалась печать №%d", i+1)
+		case s.PrevHash != prevHash:
+			problem.Message = "Печать не ссылается на предыдущую"
+		case s.Hash != domain.ChainHash(s.Sequence, s.InspectionID, s.ContentHash, s.PrevHash):
+			problem.Message = "Хэш печати не совпадает с ее содержимым"
+		}
+		if problem.Message != "" {
+			problems = append(problems, problem)
+		}
+		prevHash = s.Hash
+	}
+	return problems
+}
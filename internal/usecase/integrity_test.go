@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestCanonicalRecord(t *testing.T) {
+	finished := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inspection := &domain.Inspection{ID: uuid.New(), MachineSerial: "AB1", Verdict: domain.VerdictPass, StartedAt: finished.Add(-time.Hour), FinishedAt: &finished, SignatureKey: "sig.png"}
+	a1 := domain.InspectionAnswer{ID: uuid.New(), QuestionID: uuid.New(), Verdict: domain.VerdictPass, Photos: []domain.AnswerPhoto{{Key: "p1.jpg"}}}
+	a2 := domain.InspectionAnswer{ID: uuid.New(), QuestionID: uuid.New(), Verdict: domain.VerdictPass, Comment: "ok"}
+	hashes := map[string]string{"sig.png": "s", "p1.jpg": "p"}
+
+	base, err := canonicalRecord(inspection, []domain.InspectionAnswer{a1, a2}, hashes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Records sealed before photo metadata and originals were kept must hash the same
+	if bytes.Contains(base, []byte("Metadata")) || bytes.Contains(base, []byte("Original")) {
+		t.Errorf("photo without metadata changed the record: %s", base)
+	}
+
+	msk := finished.In(time.FixedZone("MSK", 3*60*60))
+	changedComment := a2
+	changedComment.Comment = "not ok"
+	reviewed := a1
+	reviewed.ReworkComment = "blurry"
+	withOriginal := a1
+	withOriginal.Photos = []domain.AnswerPhoto{{Key: "p1.jpg", OriginalKey: "p1.original.png"}}
+	flagged := a1
+	flagged.Photos = []domain.AnswerPhoto{{Key: "p1.jpg", PhotoMetadata: domain.PhotoMetadata{Stale: true}}}
+
+	tests := []struct {
+		name       string
+		inspection *domain.Inspection
+		answers    []domain.InspectionAnswer
+		hashes     map[string]string
+		same       bool
+	}{
+		{name: "Answers in another order", inspection: inspection, answers: []domain.InspectionAnswer{a2, a1}, hashes: hashes, same: true},
+		{name: "Another time zone", inspection: &domain.Inspection{ID: inspection.ID, MachineSerial: "AB1", Verdict: domain.VerdictPass, StartedAt: inspection.StartedAt, FinishedAt: &msk, SignatureKey: "sig.png"}, answers: []domain.InspectionAnswer{a1, a2}, hashes: hashes, same: true},
+		{name: "Review remark", inspection: inspection, answers: []domain.InspectionAnswer{reviewed, a2}, hashes: hashes, same: true},
+		{name: "Changed comment", inspection: inspection, answers: []domain.InspectionAnswer{a1, changedComment}, hashes: hashes, same: false},
+		{name: "Replaced photo content", inspection: inspection, answers: []domain.InspectionAnswer{a1, a2}, hashes: map[string]string{"sig.png": "s", "p1.jpg": "other"}, same: false},
+		{name: "Original added", inspection: inspection, answers: []domain.InspectionAnswer{withOriginal, a2}, hashes: hashes, same: false},
+		{name: "Changed photo metadata", inspection: inspection, answers: []domain.InspectionAnswer{flagged, a2}, hashes: hashes, same: false},
+		{name: "Missing answer", inspection: inspection, answers: []domain.InspectionAnswer{a1}, hashes: hashes, same: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalRecord(tt.inspection, tt.answers, tt.hashes)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bytes.Equal(got, base) != tt.same {
+				t.Errorf("expected same record: %v, got %s", tt.same, got)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	seal := func(seq int64, contentHash, prevHash string) domain.InspectionSeal {
+		id := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+		return domain.InspectionSeal{Sequence: seq, InspectionID: id, ContentHash: contentHash, PrevHash: prevHash, Hash: domain.ChainHash(seq, id, contentHash, prevHash)}
+	}
+	s1 := seal(1, "a", "")
+	s2 := seal(2, "b", s1.Hash)
+	s3 := seal(3, "c", s2.Hash)
+	edited := s2
+	edited.ContentHash = "x"
+
+	tests := []struct {
+		name     string
+		seals    []domain.InspectionSeal
+		problems []int64
+	}{
+		{name: "Empty chain"},
+		{name: "Valid chain", seals: []domain.InspectionSeal{s1, s2, s3}},
+		{name: "Edited seal", seals: []domain.InspectionSeal{s1, edited, s3}, problems: []int64{2}},
+		{name: "Removed seal", seals: []domain.InspectionSeal{s1, s3}, problems: []int64{3}},
+		{name: "Relinked seal", seals: []domain.InspectionSeal{s1, seal(2, "c", "")}, problems: []int64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := verifyChain(tt.seals)
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("expected %d problems, got %v", len(tt.problems), problems)
+			}
+			for i, p := range problems {
+				if p.Sequence != tt.problems[i] {
+					t.Errorf("expected problem at seal %d, got %d", tt.problems[i], p.Sequence)
+				}
+			}
+		})
+	}
+}
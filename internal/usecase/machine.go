@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+type MachineUseCase struct {
+	repo domain.ChecklistRepository
+}
+
+func NewMachineUseCase(repo domain.ChecklistRepository) *MachineUseCase {
+	return &MachineUseCase{repo: repo}
+}
+
+// normalizeSerial makes serials typed by hand and recognized by OCR map to the same machine.
+func normalizeSerial(serial string) string {
+	return strings.ToUpper(strings.TrimSpace(serial))
+}
+
+func (u *MachineUseCase) ListMachines(ctx context.Context, search string) ([]domain.MachineSummary, error) {
+	return u.repo.ListMachines(ctx, strings.TrimSpace(search))
+}
+
+func (u *MachineUseCase) GetMachineHistory(ctx context.Context, serial string) (*domain.MachineHistory, error) {
+	machine, err := u.repo.GetMachineBySerial(ctx, normalizeSerial(serial))
+	if err != nil {
+		return nil, err
+	}
+
+	inspections, err := u.repo.ListMachineInspections(ctx, machine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine inspections: %w", err)
+	}
+
+	overrides, err := u.repo.ListStageOverrides(ctx, machine.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stage overrides: %w", err)
+	}
+
+	return &domain.MachineHistory{
+		Machine:     *machine,
+		Inspections: inspections,
+		Overrides:   overrides,
+	}, nil
+}
+
+func (u *MachineUseCase) UpdateMachine(ctx context.Context, serial, model, productionOrder string, shippedAt *time.Time) (*domain.Machine, error) {
+	machine, err := u.repo.GetMachineBySerial(ctx, normalizeSerial(serial))
+	if err != nil {
+		return nil, err
+	}
+
+	before := *machine
+	machine.Model = strings.TrimSpace(model)
+	machine.ProductionOrder = strings.TrimSpace(productionOrder)
+	machine.ShippedAt = shippedAt
+
+	if err := u.repo.UpdateMachine(ctx, machine); err != nil {
+		return nil, fmt.Errorf("failed to update machine: %w", err)
+	}
+	recordAudit(ctx, u.repo, domain.AuditMachineUpdate, domain.AuditEntityMachine, machine.Serial, before, machine)
+	return machine, nil
+}
+
+// GrantStageOverride allows one inspection of the role to start for the
+// machine regardless of the stages it has passed.
+func (u *MachineUseCase) GrantStageOverride(ctx context.Context, serial string, role domain.Role, reason, grantedBy string) (*domain.StageOverride, error) {
+	if !role.IsValid() {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Неизвестный этап %q", role)}
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, &domain.ValidationError{Message: "Укажите причину разрешения проверки"}
+	}
+	grantedBy = strings.TrimSpace(grantedBy)
+	if grantedBy == "" {
+		return nil, fmt.Errorf("override author is required")
+	}
+
+	machine, err := u.repo.GetMachineBySerial(ctx, normalizeSerial(serial))
+	if err != nil {
+		return nil, err
+	}
+
+	override := &domain.StageOverride{
+		ID:        uuid.New(),
+		MachineID: machine.ID,
+		Role:      role,
+		Reason:    reason,
+		GrantedBy: grantedBy,
+		CreatedAt: time.Now(),
+	}
+	if err := u.repo.CreateStageOverride(ctx, override); err != nil {
+		return nil, fmt.Errorf("failed to create stage override: %w", err)
+	}
+	recordAudit(ctx, u.repo, domain.AuditStageOverride, domain.AuditEntityMachine, machine.Serial, nil, override)
+	return override, nil
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math/bits"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/bmp" // photos may be BMP
+)
+
+const (
+	defaultThumbnailSize = 320
+	photoJPEGQuality     = 85
+	// photoHeadSize is how much of the start of a photo is looked at for
+	// its format and EXIF before it is decoded
+	photoHeadSize = 512 << 10
+	// maxPhotoPixels limits the resolution of photos, as a small file can
+	// decode to an image too large for memory; 50 Mp covers phone cameras
+	maxPhotoPixels = 50_000_000
+)
+
+// errPhotoTooLarge rejects photos of more than maxPhotoPixels.
+var errPhotoTooLarge = errors.New("photo resolution is too large")
+
+// photoExtensions maps decoded image formats to the extension their
+// originals are stored with. TIFF is not accepted: its directory may follow
+// the image data, out of reach of the head read before decoding.
+var photoExtensions = map[string]string{"jpeg": "jpg", "png": "png", "gif": "gif", "bmp": "bmp"}
+
+// processedPhoto is an uploaded photo prepared for storage: JPEG derivatives
+// turned upright by EXIF orientation and what was read from the original.
+type processedPhoto struct {
+	Extension string // of the original's format
+	Metadata  domain.PhotoMetadata
+	Photo     []byte // no larger than the configured maximum
+	Thumbnail []byte
+	Hash      int64 // perceptual hash, see dHash
+	Quality   domain.PhotoQuality
+	Signature imageSignature
+}
+
+// processPhoto decodes an uploaded image as it is read, applies its EXIF
+// orientation and encodes the full-size photo and thumbnail as JPEG. A
+// non-positive maxDimension keeps the full resolution.
+func processPhoto(r io.Reader, maxDimension, thumbnailSize int) (*processedPhoto, error) {
+	br := bufio.NewReaderSize(r, photoHeadSize)
+	head, err := br.Peek(photoHeadSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image format: %w", err)
+	}
+	ext, ok := photoExtensions[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image format %s", format)
+	}
+	if int64(config.Width)*int64(config.Height) > maxPhotoPixels {
+		return nil, fmt.Errorf("%w: %dx%d", errPhotoTooLarge, config.Width, config.Height)
+	}
+	// The head is overwritten once decoding reads on
+	metadata := parseEXIF(head)
+	img, err := imaging.Decode(br, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if maxDimension > 0 {
+		img = imaging.Fit(img, maxDimension, maxDimension, imaging.Lanczos)
+	}
+	// JPEG has no transparency; lay transparent images over white once
+	// they are scaled down
+	if opaque, ok := img.(interface{ Opaque() bool }); !ok || !opaque.Opaque() {
+		bounds := img.Bounds()
+		img = imaging.Overlay(imaging.New(bounds.Dx(), bounds.Dy(), color.White), img, image.Pt(0, 0), 1)
+	}
+	if thumbnailSize <= 0 {
+		thumbnailSize = defaultThumbnailSize
+	}
+
+	photo, err := encodeJPEG(img)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := encodeJPEG(imaging.Fit(img, thumbnailSize, thumbnailSize, imaging.Lanczos))
+	if err != nil {
+		return nil, err
+	}
+	return &processedPhoto{Extension: ext, Metadata: metadata, Photo: photo, Thumbnail: thumbnail, Hash: dHash(img), Quality: measurePhotoQuality(img), Signature: photoSignature(img)}, nil
+}
+
+// dHash is a 64-bit difference hash: each bit tells whether a pixel of the
+// image shrunk to 9x8 grayscale is brighter than its right neighbour. It
+// survives re-encoding and rescaling, so copies of a photo differ in few bits.
+func dHash(img image.Image) int64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		row := small.Pix[y*small.Stride:]
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if row[x*4] > row[(x+1)*4] {
+				hash |= 1
+			}
+		}
+	}
+	return int64(hash)
+}
+
+// hashDistance is the number of bits in which two perceptual hashes differ.
+func hashDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := imaging.Encode(buf, img, imaging.JPEG, imaging.JPEGQuality(photoJPEGQuality)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// uploadPhoto stores the processed photo, its original of size bytes read
+// from original and thumbnail under keys that share base.
+func (u *InspectionUseCase) uploadPhoto(ctx context.Context, base string, p *processedPhoto, original io.Reader, size int64) (domain.AnswerPhoto, error) {
+	quality := p.Quality
+	photo := domain.AnswerPhoto{PhotoMetadata: p.Metadata, PerceptualHash: p.Hash, Quality: &quality}
+	var err error
+	if photo.OriginalKey, err = u.storage.Upload(ctx, "", base+".original."+p.Extension, original, size, ""); err != nil {
+		return photo, err
+	}
+	if photo.Key, err = u.storage.Upload(ctx, "", base+".jpg", bytes.NewReader(p.Photo), int64(len(p.Photo)), "image/jpeg"); err != nil {
+		return photo, err
+	}
+	if photo.ThumbnailKey, err = u.storage.Upload(ctx, "", base+".thumb.jpg", bytes.NewReader(p.Thumbnail), int64(len(p.Thumbnail)), "image/jpeg"); err != nil {
+		return photo, err
+	}
+	return photo, nil
+}
+
+// deletePhotos removes the stored files of photos no answer was saved with.
+func (u *InspectionUseCase) deletePhotos(ctx context.Context, photos []domain.AnswerPhoto) {
+	for _, p := range photos {
+		for _, key := range []string{p.OriginalKey, p.Key, p.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := u.storage.Delete(ctx, "", key); err != nil {
+				log.Printf("Failed to delete photo %s: %v", key, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// qualityAnalysisSize is the longest side photos are scaled down to
+	// before measuring, so thresholds do not depend on the resolution
+	qualityAnalysisSize = 800
+	// clippedLuminance is the luminance from which a pixel is blown out
+	clippedLuminance = 250
+)
+
+// measurePhotoQuality measures sharpness as the variance of the Laplacian of
+// luminance, mean brightness and the share of blown-out pixels.
+func measurePhotoQuality(img image.Image) domain.PhotoQuality {
+	gray := imaging.Grayscale(imaging.Fit(img, qualityAnalysisSize, qualityAnalysisSize, imaging.Box))
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return domain.PhotoQuality{}
+	}
+	lum := func(x, y int) float64 {
+		return float64(gray.Pix[y*gray.Stride+x*4])
+	}
+
+	var q domain.PhotoQuality
+	var sum, clipped float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := lum(x, y)
+			sum += v
+			if v >= clippedLuminance {
+				clipped++
+			}
+		}
+	}
+	q.Brightness = sum / float64(w*h)
+	q.Clipped = clipped / float64(w*h)
+
+	var n, lapSum, lapSq float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			l := lum(x-1, y) + lum(x+1, y) + lum(x, y-1) + lum(x, y+1) - 4*lum(x, y)
+			lapSum += l
+			lapSq += l * l
+			n++
+		}
+	}
+	if n > 0 {
+		mean := lapSum / n
+		q.Sharpness = lapSq/n - mean*mean
+	}
+	return q
+}
+
+// photoQualityIssues returns the checks of the question the photo fails.
+func photoQualityIssues(q domain.PhotoQuality, cfg *domain.PhotoQualityConfig) []domain.PhotoIssue {
+	if cfg == nil {
+		return nil
+	}
+	var issues []domain.PhotoIssue
+	if cfg.MinSharpness > 0 && q.Sharpness < cfg.MinSharpness {
+		issues = append(issues, domain.PhotoBlurry)
+	}
+	if cfg.MinBrightness > 0 && q.Brightness < cfg.MinBrightness {
+		issues = append(issues, domain.PhotoDark)
+	}
+	if cfg.MaxClipped > 0 && q.Clipped > cfg.MaxClipped {
+		issues = append(issues, domain.PhotoOverexposed)
+	}
+	return issues
+}
+
+// photoIssuesText lists the issues for a message to the inspector.
+func photoIssuesText(issues []domain.PhotoIssue) string {
+	titles := make([]string, len(issues))
+	for i, issue := range issues {
+		titles[i] = issue.Title()
+	}
+	return strings.Join(titles, ", ")
+}
+
+// validatePhotoQuality checks the thresholds of a question's photo quality
+// config.
+func validatePhotoQuality(q *domain.Question) error {
+	cfg := q.Config.PhotoQuality
+	if cfg == nil {
+		return nil
+	}
+	switch {
+	case q.MaxPhotos == 0:
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: проверка качества задана, но фото не прикладываются", q.Order)}
+	case cfg.MinSharpness < 0:
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: порог резкости не может быть отрицательным", q.Order)}
+	case cfg.MinBrightness < 0 || cfg.MinBrightness > 255:
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: порог яркости должен быть от 0 до 255", q.Order)}
+	case cfg.MaxClipped < 0 || cfg.MaxClipped > 1:
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: доля пересвеченных пикселей должна быть от 0 до 1", q.Order)}
+	}
+	return nil
+}
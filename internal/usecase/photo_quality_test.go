@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestMeasurePhotoQuality(t *testing.T) {
+	checkerboard := image.NewGray(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			if (x/4+y/4)%2 == 0 {
+				checkerboard.SetGray(x, y, color.Gray{Y: 230})
+			} else {
+				checkerboard.SetGray(x, y, color.Gray{Y: 30})
+			}
+		}
+	}
+	sharp := measurePhotoQuality(checkerboard)
+	blurred := measurePhotoQuality(imaging.Blur(checkerboard, 4))
+	if blurred.Sharpness >= sharp.Sharpness/10 {
+		t.Errorf("expected blurring to lower sharpness, got %.1f for sharp and %.1f for blurred", sharp.Sharpness, blurred.Sharpness)
+	}
+	if sharp.Brightness < 120 || sharp.Brightness > 140 || sharp.Clipped != 0 {
+		t.Errorf("unexpected brightness %.1f and clipped %.2f of checkerboard", sharp.Brightness, sharp.Clipped)
+	}
+
+	dark := measurePhotoQuality(imaging.New(100, 100, color.Gray{Y: 10}))
+	if dark.Brightness != 10 || dark.Sharpness != 0 {
+		t.Errorf("expected flat dark image to have brightness 10 and no sharpness, got %.1f and %.1f", dark.Brightness, dark.Sharpness)
+	}
+	white := measurePhotoQuality(imaging.New(100, 100, color.White))
+	if white.Clipped != 1 {
+		t.Errorf("expected white image to be fully clipped, got %.2f", white.Clipped)
+	}
+}
+
+func TestPhotoQualityIssues(t *testing.T) {
+	cfg := &domain.PhotoQualityConfig{MinSharpness: 100, MinBrightness: 40, MaxClipped: 0.2}
+
+	tests := []struct {
+		name    string
+		quality domain.PhotoQuality
+		cfg     *domain.PhotoQualityConfig
+		want    []domain.PhotoIssue
+	}{
+		{name: "Good photo", quality: domain.PhotoQuality{Sharpness: 500, Brightness: 120, Clipped: 0.01}, cfg: cfg},
+		{name: "No checks", quality: domain.PhotoQuality{}, cfg: nil},
+		{name: "Blurry and dark", quality: domain.PhotoQuality{Sharpness: 20, Brightness: 15}, cfg: cfg, want: []domain.PhotoIssue{domain.PhotoBlurry, domain.PhotoDark}},
+		{name: "Overexposed", quality: domain.PhotoQuality{Sharpness: 500, Brightness: 240, Clipped: 0.6}, cfg: cfg, want: []domain.PhotoIssue{domain.PhotoOverexposed}},
+		{name: "Zero threshold is not checked", quality: domain.PhotoQuality{Clipped: 0.9}, cfg: &domain.PhotoQualityConfig{MinSharpness: 100}, want: []domain.PhotoIssue{domain.PhotoBlurry}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := photoQualityIssues(tt.quality, tt.cfg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"math"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // reference images may be WebP
+)
+
+const (
+	// signatureSize is the longest side images are scaled down to before
+	// their signature is computed
+	signatureSize = 128
+	hueBins       = 12
+	// Colours this unsaturated or dark are binned by brightness alone
+	minChromaSaturation = 0.2
+	minChromaValue      = 0.15
+	grayBins            = 4
+	colorBins           = hueBins*2 + grayBins
+	orientationBins     = 8
+	// edgeCells is the number of cells per side of the grid edge
+	// orientations are counted in
+	edgeCells = 2
+)
+
+// imageSignature describes what an image shows coarsely enough to compare
+// photos of the same subject taken from a slightly different angle or in
+// different light.
+type imageSignature struct {
+	// Colors is the hue and saturation histogram, normalized to sum to 1
+	Colors [colorBins]float64
+	// Edges is the histogram of gradient orientations in each cell of a
+	// grid, weighted by gradient magnitude and normalized to sum to 1
+	Edges [edgeCells * edgeCells * orientationBins]float64
+}
+
+// photoSignature computes the signature of an image.
+func photoSignature(img image.Image) imageSignature {
+	var sig imageSignature
+	small := imaging.Fit(img, signatureSize, signatureSize, imaging.Box)
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+	if w < 3 || h < 3 {
+		return sig
+	}
+
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px := small.Pix[y*small.Stride+x*4:]
+			r, g, b := float64(px[0])/255, float64(px[1])/255, float64(px[2])/255
+			sig.Colors[colorBin(r, g, b)]++
+			lum[y*w+x] = 0.299*r + 0.587*g + 0.114*b
+		}
+	}
+	normalize(sig.Colors[:])
+
+	// Sobel gradients; orientation is taken modulo 180 degrees so an edge
+	// counts the same whichever side is brighter
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			at := func(dx, dy int) float64 { return lum[(y+dy)*w+x+dx] }
+			gx := at(1, -1) + 2*at(1, 0) + at(1, 1) - at(-1, -1) - 2*at(-1, 0) - at(-1, 1)
+			gy := at(-1, 1) + 2*at(0, 1) + at(1, 1) - at(-1, -1) - 2*at(0, -1) - at(1, -1)
+			magnitude := math.Hypot(gx, gy)
+			if magnitude == 0 {
+				continue
+			}
+			angle := math.Atan2(gy, gx)
+			if angle < 0 {
+				angle += math.Pi
+			}
+			bin := min(int(angle/math.Pi*orientationBins), orientationBins-1)
+			cell := (y*edgeCells/h)*edgeCells + x*edgeCells/w
+			sig.Edges[cell*orientationBins+bin] += magnitude
+		}
+	}
+	normalize(sig.Edges[:])
+	return sig
+}
+
+// colorBin returns the histogram bin of a colour: hue in twelve sectors at
+// low and high saturation, or one of four brightness levels for grays.
+func colorBin(r, g, b float64) int {
+	hi, lo := max(r, g, b), min(r, g, b)
+	if hi < minChromaValue || hi-lo < minChromaSaturation*hi {
+		return hueBins*2 + min(int(hi*grayBins), grayBins-1)
+	}
+	var hue float64 // in sectors of 60 degrees
+	switch delta := hi - lo; hi {
+	case r:
+		hue = math.Mod((g-b)/delta+6, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	bin := min(int(hue/6*hueBins), hueBins-1)
+	if (hi-lo)/hi >= 0.5 {
+		bin += hueBins
+	}
+	return bin
+}
+
+func normalize(hist []float64) {
+	var sum float64
+	for _, v := range hist {
+		sum += v
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range hist {
+		hist[i] /= sum
+	}
+}
+
+// histogramIntersection is the overlap of two normalized histograms, from 0
+// for nothing in common to 1 for identical ones.
+func histogramIntersection(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += min(a[i], b[i])
+	}
+	return sum
+}
+
+// signatureSimilarity scores from 0 to 1 how alike two images are, giving
+// colours and edge structure equal weight.
+func signatureSimilarity(a, b imageSignature) float64 {
+	return (histogramIntersection(a.Colors[:], b.Colors[:]) + histogramIntersection(a.Edges[:], b.Edges[:])) / 2
+}
+
+// referenceSimilarity is the similarity of a photo to the closest of the
+// reference images.
+func referenceSimilarity(photo imageSignature, references []imageSignature) float64 {
+	best := 0.0
+	for _, ref := range references {
+		best = max(best, signatureSimilarity(photo, ref))
+	}
+	return best
+}
+
+// referenceSignatures returns the signatures of reference images by storage
+// key. Reference keys are never reused for other content, so signatures are
+// computed once per process. Images that cannot be read are logged and
+// skipped.
+func (u *InspectionUseCase) referenceSignatures(ctx context.Context, keys []string) []imageSignature {
+	var signatures []imageSignature
+	for _, key := range keys {
+		u.refMu.Lock()
+		sig, ok := u.refSignatures[key]
+		u.refMu.Unlock()
+		if !ok {
+			var err error
+			if sig, err = u.loadReferenceSignature(ctx, key); err != nil {
+				log.Printf("Reference similarity: %v", err)
+				continue
+			}
+			u.refMu.Lock()
+			u.refSignatures[key] = sig
+			u.refMu.Unlock()
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures
+}
+
+func (u *InspectionUseCase) loadReferenceSignature(ctx context.Context, key string) (imageSignature, error) {
+	file, err := u.storage.Open(ctx, "", key)
+	if err != nil {
+		return imageSignature{}, fmt.Errorf("failed to download reference image %s: %w", key, err)
+	}
+	defer file.Close()
+	img, err := imaging.Decode(file, imaging.AutoOrientation(true))
+	if err != nil {
+		return imageSignature{}, fmt.Errorf("failed to decode reference image %s: %w", key, err)
+	}
+	return photoSignature(img), nil
+}
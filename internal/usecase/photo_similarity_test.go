@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// stripes draws a w x h image of stripes of two colours, vertical or
+// horizontal.
+func stripes(w, h int, a, b color.Color, vertical bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pos := y
+			if vertical {
+				pos = x
+			}
+			if pos/10%2 == 0 {
+				img.Set(x, y, a)
+			} else {
+				img.Set(x, y, b)
+			}
+		}
+	}
+	return img
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	red, yellow := color.NRGBA{R: 200, G: 30, B: 30, A: 255}, color.NRGBA{R: 230, G: 220, B: 40, A: 255}
+	blue, gray := color.NRGBA{R: 30, G: 60, B: 200, A: 255}, color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+	reference := stripes(400, 300, red, yellow, true)
+	sig := photoSignature(reference)
+
+	tests := []struct {
+		name    string
+		photo   image.Image
+		atLeast float64
+		below   float64
+	}{
+		{name: "Same image", photo: reference, atLeast: 0.999, below: 1.001},
+		{name: "Resized", photo: imaging.Resize(reference, 1200, 900, imaging.Lanczos), atLeast: 0.85, below: 1.001},
+		{name: "Cropped and brighter", photo: imaging.AdjustBrightness(imaging.CropCenter(reference, 320, 240), 10), atLeast: 0.75, below: 1.001},
+		{name: "Other colours and direction", photo: stripes(400, 300, blue, gray, false), below: 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := signatureSimilarity(sig, photoSignature(tt.photo))
+			if got < tt.atLeast || got >= tt.below {
+				t.Errorf("expected similarity in [%.2f, %.2f), got %.3f", tt.atLeast, tt.below, got)
+			}
+		})
+	}
+}
+
+func TestReferenceSimilarity(t *testing.T) {
+	photo := photoSignature(stripes(100, 100, color.White, color.Black, true))
+	same := photo
+	other := photoSignature(stripes(100, 100, color.NRGBA{R: 200, A: 255}, color.NRGBA{B: 200, A: 255}, false))
+
+	if got := referenceSimilarity(photo, []imageSignature{other, same}); got < 0.999 {
+		t.Errorf("expected the closest reference to count, got %.3f", got)
+	}
+	if got := referenceSimilarity(photo, nil); got != 0 {
+		t.Errorf("expected zero without references, got %.3f", got)
+	}
+}
+
+func TestColorBin(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b float64
+		want    int
+	}{
+		{name: "Black", r: 0, g: 0, b: 0, want: hueBins * 2},
+		{name: "White", r: 1, g: 1, b: 1, want: hueBins*2 + grayBins - 1},
+		{name: "Saturated red", r: 1, g: 0, b: 0, want: hueBins},
+		{name: "Pale red", r: 1, g: 0.7, b: 0.7, want: 0},
+		{name: "Saturated blue", r: 0, g: 0, b: 1, want: hueBins + 8},
+		{name: "Gray", r: 0.5, g: 0.5, b: 0.45, want: hueBins*2 + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := colorBin(tt.r, tt.g, tt.b); got != tt.want {
+				t.Errorf("expected bin %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func encodeTestImage(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "bmp":
+		err = bmp.Encode(buf, img)
+	case "tiff":
+		err = tiff.Encode(buf, img, nil)
+	default:
+		err = jpeg.Encode(buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment with the orientation tag after the
+// start of a JPEG image.
+func withOrientation(data []byte, orientation uint16) []byte {
+	entry := tiffEntry{tag: exifTagOrientation, typ: 3, count: 1, value: binary.BigEndian.AppendUint16(nil, orientation)}
+	exif := buildJPEG([]tiffEntry{entry}, nil, nil)
+	app1 := exif[2 : 4+int(binary.BigEndian.Uint16(exif[4:6]))]
+	return append(append([]byte{0xFF, 0xD8}, app1...), data[2:]...)
+}
+
+func TestProcessPhoto(t *testing.T) {
+	landscape := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	for i := range landscape.Pix {
+		landscape.Pix[i] = 200
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	transparent.Set(0, 0, color.NRGBA{R: 255, A: 255})
+
+	tests := []struct {
+		name        string
+		data        []byte
+		maxDim      int
+		ext         string
+		orientation int
+		photo       image.Point
+		thumbnail   image.Point
+		wantError   bool
+	}{
+		{name: "JPEG scaled down", data: encodeTestImage(t, landscape, "jpeg"), maxDim: 200, ext: "jpg", photo: image.Pt(200, 50), thumbnail: image.Pt(40, 10)},
+		{name: "Small photo keeps its size", data: encodeTestImage(t, landscape, "jpeg"), maxDim: 1000, ext: "jpg", photo: image.Pt(400, 100), thumbnail: image.Pt(40, 10)},
+		{name: "No maximum", data: encodeTestImage(t, landscape, "jpeg"), maxDim: 0, ext: "jpg", photo: image.Pt(400, 100), thumbnail: image.Pt(40, 10)},
+		{name: "Rotated by EXIF", data: withOrientation(encodeTestImage(t, landscape, "jpeg"), 6), maxDim: 1000, ext: "jpg", orientation: 6, photo: image.Pt(100, 400), thumbnail: image.Pt(10, 40)},
+		{name: "PNG converted to JPEG", data: encodeTestImage(t, transparent, "png"), maxDim: 1000, ext: "png", photo: image.Pt(50, 50), thumbnail: image.Pt(40, 40)},
+		{name: "BMP converted to JPEG", data: encodeTestImage(t, landscape, "bmp"), maxDim: 1000, ext: "bmp", photo: image.Pt(400, 100), thumbnail: image.Pt(40, 10)},
+		{name: "TIFF is not accepted", data: encodeTestImage(t, landscape, "tiff"), wantError: true},
+		{name: "Not an image", data: []byte("not an image"), wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processPhoto(bytes.NewReader(tt.data), tt.maxDim, 40)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("expected error: %v, got %v", tt.wantError, err)
+			}
+			if tt.wantError {
+				return
+			}
+			if got.Extension != tt.ext {
+				t.Errorf("expected extension %s, got %s", tt.ext, got.Extension)
+			}
+			if got.Metadata.Orientation != tt.orientation {
+				t.Errorf("expected EXIF orientation %d, got %d", tt.orientation, got.Metadata.Orientation)
+			}
+			for _, c := range []struct {
+				name string
+				data []byte
+				size image.Point
+			}{{"photo", got.Photo, tt.photo}, {"thumbnail", got.Thumbnail, tt.thumbnail}} {
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(c.data))
+				if err != nil {
+					t.Fatalf("failed to decode %s: %v", c.name, err)
+				}
+				if format != "jpeg" || cfg.Width != c.size.X || cfg.Height != c.size.Y {
+					t.Errorf("expected %s to be %dx%d jpeg, got %dx%d %s", c.name, c.size.X, c.size.Y, cfg.Width, cfg.Height, format)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessPhotoTransparency(t *testing.T) {
+	transparent := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	got, err := processPhoto(bytes.NewReader(encodeTestImage(t, transparent, "png")), 50, 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(got.Photo))
+	if err != nil {
+		t.Fatalf("failed to decode photo: %v", err)
+	}
+	if r, g, b, _ := img.At(25, 25).RGBA(); r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("expected transparent pixels to turn white, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestProcessPhotoTooLarge(t *testing.T) {
+	// A small BMP whose header claims 20000x20000 pixels
+	data := encodeTestImage(t, image.NewNRGBA(image.Rect(0, 0, 10, 10)), "bmp")
+	binary.LittleEndian.PutUint32(data[18:22], 20000)
+	binary.LittleEndian.PutUint32(data[22:26], 20000)
+
+	if _, err := processPhoto(bytes.NewReader(data), 1000, 40); !errors.Is(err, errPhotoTooLarge) {
+		t.Errorf("expected the photo to be rejected as too large, got %v", err)
+	}
+}
+
+func TestDHash(t *testing.T) {
+	// Diagonal stripes with a bright block, and a differently striped image
+	pattern := func(w, h, period int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := uint8(((x + y) * 255 / period) % 256)
+				if x > w/2 && y < h/3 {
+					v = 250
+				}
+				img.Set(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+			}
+		}
+		return img
+	}
+	original := pattern(360, 240, 300)
+	decode := func(data []byte) image.Image {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		return img
+	}
+	reencoded := decode(encodeTestImage(t, original, "jpeg"))
+	small, err := processPhoto(bytes.NewReader(encodeTestImage(t, original, "png")), 120, 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		maxDist int
+		minDist int
+	}{
+		{name: "Same image", img: original, maxDist: 0},
+		{name: "Re-encoded as JPEG", img: reencoded, maxDist: duplicatePhotoDistance},
+		{name: "Scaled down", img: decode(small.Photo), maxDist: duplicatePhotoDistance},
+		{name: "Different image", img: pattern(360, 240, 70), minDist: duplicatePhotoDistance + 1, maxDist: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := hashDistance(dHash(original), dHash(tt.img))
+			if d < tt.minDist || d > tt.maxDist {
+				t.Errorf("expected distance in [%d, %d], got %d", tt.minDist, tt.maxDist, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+// validateQuestion checks the type-specific configuration of a template
+// question. Questions without a type are photo questions.
+func validateQuestion(q *domain.Question) error {
+	if q.Type == "" {
+		q.Type = domain.QuestionPhoto
+	}
+	if !q.Type.IsValid() {
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: неизвестный тип %q", q.Order, q.Type)}
+	}
+	if q.MinPhotos < 0 || q.MaxPhotos < q.MinPhotos {
+		return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: неверные ограничения на количество фото", q.Order)}
+	}
+	if err := validatePhotoQuality(q); err != nil {
+		return err
+	}
+
+	cfg := q.Config
+	switch q.Type {
+	case domain.QuestionNumber:
+		if cfg.Min == nil && cfg.Max == nil {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: укажите допуск min и/или max", q.Order)}
+		}
+		if cfg.Min != nil && cfg.Max != nil && *cfg.Min > *cfg.Max {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: min больше max", q.Order)}
+		}
+	case domain.QuestionSingleChoice, domain.QuestionMultiChoice:
+		if len(cfg.Options) < 2 {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: нужно минимум два варианта ответа", q.Order)}
+		}
+		for _, option := range cfg.FailOptions {
+			if !slices.Contains(cfg.Options, option) {
+				return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: вариант %q не входит в список ответов", q.Order, option)}
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateAnswer validates the typed value against the question and returns
+// the verdict to store. A value that breaks the question's tolerance fails the
+// check regardless of the verdict chosen by the inspector; otherwise a missing
+// verdict means pass for questions whose value decides the result.
+func evaluateAnswer(q domain.Question, value *domain.AnswerValue, verdict domain.Verdict) (domain.Verdict, error) {
+	if verdict != "" && !verdict.IsValid() {
+		return "", &domain.ValidationError{Message: fmt.Sprintf("Недопустимый результат проверки %q", verdict)}
+	}
+
+	failed := false
+	switch q.Type {
+	case domain.QuestionYesNo:
+		if value == nil || value.Bool == nil {
+			if verdict != domain.VerdictNotApplicable {
+				return "", &domain.ValidationError{Message: "Ответьте «Да» или «Нет»"}
+			}
+			break
+		}
+		failed = !*value.Bool
+	case domain.QuestionNumber:
+		if value == nil || value.Number == nil {
+			if verdict != domain.VerdictNotApplicable {
+				return "", &domain.ValidationError{Message: "Введите измеренное значение"}
+			}
+			break
+		}
+		n := *value.Number
+		failed = (q.Config.Min != nil && n < *q.Config.Min) || (q.Config.Max != nil && n > *q.Config.Max)
+	case domain.QuestionSingleChoice, domain.QuestionMultiChoice:
+		if value == nil || len(value.Choices) == 0 {
+			if verdict != domain.VerdictNotApplicable {
+				return "", &domain.ValidationError{Message: "Выберите вариант ответа"}
+			}
+			break
+		}
+		if q.Type == domain.QuestionSingleChoice && len(value.Choices) > 1 {
+			return "", &domain.ValidationError{Message: "Выберите один вариант ответа"}
+		}
+		for _, choice := range value.Choices {
+			if !slices.Contains(q.Config.Options, choice) {
+				return "", &domain.ValidationError{Message: fmt.Sprintf("Недопустимый вариант ответа %q", choice)}
+			}
+			if slices.Contains(q.Config.FailOptions, choice) {
+				failed = true
+			}
+		}
+	case domain.QuestionText:
+		if q.IsRequired && (value == nil || strings.TrimSpace(value.Text) == "") && verdict != domain.VerdictNotApplicable {
+			return "", &domain.ValidationError{Message: "Заполните текстовое поле"}
+		}
+	}
+
+	switch {
+	case failed:
+		return domain.VerdictFail, nil
+	case verdict != "":
+		return verdict, nil
+	case q.Type == domain.QuestionPhoto || q.Type == domain.QuestionText:
+		return "", &domain.ValidationError{Message: "Укажите результат проверки"}
+	default:
+		return domain.VerdictPass, nil
+	}
+}
+
+// checkPhotoCount checks the number of photos attached to an answer against
+// the question's limits. Answers marked as not applicable need no photos.
+func checkPhotoCount(q domain.Question, count int, verdict domain.Verdict) error {
+	switch {
+	case q.MaxPhotos == 0 && count > 0:
+		return &domain.ValidationError{Message: "К этому вопросу фото не прикладываются"}
+	case count > q.MaxPhotos:
+		return &domain.ValidationError{Message: fmt.Sprintf("Можно приложить не более %d фото", q.MaxPhotos)}
+	case count < q.MinPhotos && verdict != domain.VerdictNotApplicable:
+		return &domain.ValidationError{Message: fmt.Sprintf("Приложите минимум %d фото", q.MinPhotos)}
+	}
+	return nil
+}
+
+// validateTemplateQuestions validates every question and makes sure show-if
+// conditions refer to an earlier question of the same template.
+func validateTemplateQuestions(questions []domain.Question) error {
+	byOrder := make(map[int]domain.Question)
+	for i := range questions {
+		if err := validateQuestion(&questions[i]); err != nil {
+			return err
+		}
+		byOrder[questions[i].Order] = questions[i]
+	}
+
+	for _, q := range questions {
+		c := q.ShowIf
+		if c == nil {
+			continue
+		}
+		ref, ok := byOrder[c.Question]
+		if !ok || c.Question >= q.Order {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: условие должно ссылаться на один из предыдущих вопросов", q.Order)}
+		}
+		if len(c.Verdicts) == 0 && c.Bool == nil && len(c.Choices) == 0 {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: условие не задано", q.Order)}
+		}
+		for _, v := range c.Verdicts {
+			if !v.IsValid() {
+				return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: неизвестный результат %q в условии", q.Order, v)}
+			}
+		}
+		if c.Bool != nil && ref.Type != domain.QuestionYesNo {
+			return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: условие «да/нет» возможно только для вопроса типа yes_no", q.Order)}
+		}
+		for _, choice := range c.Choices {
+			if !slices.Contains(ref.Config.Options, choice) {
+				return &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: вариант %q не входит в ответы вопроса %d", q.Order, choice, ref.Order)}
+			}
+		}
+	}
+	return nil
+}
+
+// visibleQuestions returns the questions the inspector has to answer given the
+// answers so far. A conditional question stays hidden until the question it
+// depends on is visible and answered accordingly.
+func visibleQuestions(questions []domain.Question, answers []domain.InspectionAnswer) []domain.Question {
+	latest := latestAnswers(answers)
+
+	visible := make(map[int]bool)
+	var result []domain.Question
+	for _, q := range questions {
+		if c := q.ShowIf; c != nil {
+			ref := slices.IndexFunc(questions, func(other domain.Question) bool { return other.Order == c.Question })
+			if ref < 0 || !visible[c.Question] {
+				continue
+			}
+			answer, ok := latest[questions[ref].ID]
+			if !ok || !conditionMet(c, answer) {
+				continue
+			}
+		}
+		visible[q.Order] = true
+		result = append(result, q)
+	}
+	return result
+}
+
+// latestAnswers keys the most recent answer by question.
+func latestAnswers(answers []domain.InspectionAnswer) map[uuid.UUID]domain.InspectionAnswer {
+	latest := make(map[uuid.UUID]domain.InspectionAnswer)
+	for _, a := range answers {
+		if prev, ok := latest[a.QuestionID]; !ok || a.CreatedAt.After(prev.CreatedAt) {
+			latest[a.QuestionID] = a
+		}
+	}
+	return latest
+}
+
+func conditionMet(c *domain.QuestionCondition, a domain.InspectionAnswer) bool {
+	if len(c.Verdicts) > 0 && !slices.Contains(c.Verdicts, a.Verdict) {
+		return false
+	}
+	if c.Bool != nil && (a.Value == nil || a.Value.Bool == nil || *a.Value.Bool != *c.Bool) {
+		return false
+	}
+	if len(c.Choices) > 0 {
+		if a.Value == nil || !slices.ContainsFunc(a.Value.Choices, func(choice string) bool { return slices.Contains(c.Choices, choice) }) {
+			return false
+		}
+	}
+	return true
+}
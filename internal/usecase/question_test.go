@@ -0,0 +1,307 @@
+package usecase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestEvaluateAnswer(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	yes, no := true, false
+
+	number := domain.Question{Type: domain.QuestionNumber, Config: domain.QuestionConfig{Min: float(11.5), Max: float(12.5), Unit: "V"}}
+	choice := domain.Question{Type: domain.QuestionSingleChoice, Config: domain.QuestionConfig{Options: []string{"OK", "Scratched", "Broken"}, FailOptions: []string{"Broken"}}}
+	multi := domain.Question{Type: domain.QuestionMultiChoice, Config: choice.Config}
+
+	tests := []struct {
+		name      string
+		question  domain.Question
+		value     *domain.AnswerValue
+		verdict   domain.Verdict
+		expected  domain.Verdict
+		wantError bool
+	}{
+		{
+			name:     "Photo question keeps inspector verdict",
+			question: domain.Question{Type: domain.QuestionPhoto},
+			verdict:  domain.VerdictPass,
+			expected: domain.VerdictPass,
+		},
+		{
+			name:      "Photo question requires verdict",
+			question:  domain.Question{Type: domain.QuestionPhoto},
+			wantError: true,
+		},
+		{
+			name:     "Yes passes",
+			question: domain.Question{Type: domain.QuestionYesNo},
+			value:    &domain.AnswerValue{Bool: &yes},
+			expected: domain.VerdictPass,
+		},
+		{
+			name:     "No fails even if marked as pass",
+			question: domain.Question{Type: domain.QuestionYesNo},
+			value:    &domain.AnswerValue{Bool: &no},
+			verdict:  domain.VerdictPass,
+			expected: domain.VerdictFail,
+		},
+		{
+			name:     "Number within tolerance",
+			question: number,
+			value:    &domain.AnswerValue{Number: float(12)},
+			expected: domain.VerdictPass,
+		},
+		{
+			name:     "Number on tolerance boundary",
+			question: number,
+			value:    &domain.AnswerValue{Number: float(12.5)},
+			expected: domain.VerdictPass,
+		},
+		{
+			name:     "Number below tolerance fails",
+			question: number,
+			value:    &domain.AnswerValue{Number: float(11.4)},
+			expected: domain.VerdictFail,
+		},
+		{
+			name:     "Inspector can fail a number within tolerance",
+			question: number,
+			value:    &domain.AnswerValue{Number: float(12)},
+			verdict:  domain.VerdictFail,
+			expected: domain.VerdictFail,
+		},
+		{
+			name:      "Number is required",
+			question:  number,
+			wantError: true,
+		},
+		{
+			name:     "Missing number allowed when not applicable",
+			question: number,
+			verdict:  domain.VerdictNotApplicable,
+			expected: domain.VerdictNotApplicable,
+		},
+		{
+			name:     "Fail option fails",
+			question: choice,
+			value:    &domain.AnswerValue{Choices: []string{"Broken"}},
+			expected: domain.VerdictFail,
+		},
+		{
+			name:      "Unknown option is rejected",
+			question:  choice,
+			value:     &domain.AnswerValue{Choices: []string{"Lost"}},
+			wantError: true,
+		},
+		{
+			name:      "Single choice rejects several options",
+			question:  choice,
+			value:     &domain.AnswerValue{Choices: []string{"OK", "Scratched"}},
+			wantError: true,
+		},
+		{
+			name:     "Multi choice with one fail option fails",
+			question: multi,
+			value:    &domain.AnswerValue{Choices: []string{"Scratched", "Broken"}},
+			expected: domain.VerdictFail,
+		},
+		{
+			name:      "Required text must be filled",
+			question:  domain.Question{Type: domain.QuestionText, IsRequired: true},
+			value:     &domain.AnswerValue{Text: "  "},
+			verdict:   domain.VerdictPass,
+			wantError: true,
+		},
+		{
+			name:      "Unknown verdict is rejected",
+			question:  domain.Question{Type: domain.QuestionPhoto},
+			verdict:   domain.Verdict("maybe"),
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateAnswer(tt.question, tt.value, tt.verdict)
+			if tt.wantError {
+				var validationErr *domain.ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Errorf("expected validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateQuestion(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		question  domain.Question
+		wantError bool
+	}{
+		{name: "Untyped question is a photo question", question: domain.Question{}},
+		{name: "Unknown type", question: domain.Question{Type: "slider"}, wantError: true},
+		{name: "Number without tolerance", question: domain.Question{Type: domain.QuestionNumber}, wantError: true},
+		{name: "Number with min only", question: domain.Question{Type: domain.QuestionNumber, Config: domain.QuestionConfig{Min: float(1)}}},
+		{name: "Number with min above max", question: domain.Question{Type: domain.QuestionNumber, Config: domain.QuestionConfig{Min: float(2), Max: float(1)}}, wantError: true},
+		{name: "Choice with one option", question: domain.Question{Type: domain.QuestionSingleChoice, Config: domain.QuestionConfig{Options: []string{"A"}}}, wantError: true},
+		{name: "Max photos below min", question: domain.Question{MinPhotos: 2, MaxPhotos: 1}, wantError: true},
+		{name: "Fail option not among options", question: domain.Question{Type: domain.QuestionMultiChoice, Config: domain.QuestionConfig{Options: []string{"A", "B"}, FailOptions: []string{"C"}}}, wantError: true},
+		{name: "Photo quality checks", question: domain.Question{MaxPhotos: 3, Config: domain.QuestionConfig{PhotoQuality: &domain.PhotoQualityConfig{MinSharpness: 100, MinBrightness: 40, MaxClipped: 0.2}}}},
+		{name: "Photo quality without photos", question: domain.Question{Type: domain.QuestionYesNo, Config: domain.QuestionConfig{PhotoQuality: &domain.PhotoQualityConfig{MinSharpness: 100}}}, wantError: true},
+		{name: "Clipped share above one", question: domain.Question{MaxPhotos: 3, Config: domain.QuestionConfig{PhotoQuality: &domain.PhotoQualityConfig{MaxClipped: 20}}}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.question
+			err := validateQuestion(&q)
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, got %v", tt.wantError, err)
+			}
+			if err == nil && q.Type == "" {
+				t.Errorf("expected type to be set")
+			}
+		})
+	}
+}
+
+func TestCheckPhotoCount(t *testing.T) {
+	photo := domain.Question{Type: domain.QuestionPhoto, MinPhotos: 1, MaxPhotos: 5}
+	noPhotos := domain.Question{Type: domain.QuestionYesNo}
+
+	tests := []struct {
+		name      string
+		question  domain.Question
+		count     int
+		verdict   domain.Verdict
+		wantError bool
+	}{
+		{name: "Within limits", question: photo, count: 3, verdict: domain.VerdictPass},
+		{name: "Too few", question: photo, count: 0, verdict: domain.VerdictPass, wantError: true},
+		{name: "Too many", question: photo, count: 6, verdict: domain.VerdictFail, wantError: true},
+		{name: "Not applicable needs no photos", question: photo, count: 0, verdict: domain.VerdictNotApplicable},
+		{name: "Not applicable keeps the maximum", question: photo, count: 6, verdict: domain.VerdictNotApplicable, wantError: true},
+		{name: "Question without photos", question: noPhotos, count: 1, verdict: domain.VerdictPass, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPhotoCount(tt.question, tt.count, tt.verdict)
+			var validationErr *domain.ValidationError
+			if errors.As(err, &validationErr) != tt.wantError {
+				t.Errorf("expected validation error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestVisibleQuestions(t *testing.T) {
+	yes, no := true, false
+	filters := domain.Question{ID: uuid.New(), Order: 1, Type: domain.QuestionYesNo}
+	why := domain.Question{ID: uuid.New(), Order: 2, Type: domain.QuestionText, ShowIf: &domain.QuestionCondition{Question: 1, Bool: &no}}
+	display := domain.Question{ID: uuid.New(), Order: 3, ShowIf: &domain.QuestionCondition{Question: 2, Verdicts: []domain.Verdict{domain.VerdictFail}}}
+	sim := domain.Question{ID: uuid.New(), Order: 4}
+	questions := []domain.Question{filters, why, display, sim}
+
+	answer := func(q domain.Question, verdict domain.Verdict, value *domain.AnswerValue, at time.Time) domain.InspectionAnswer {
+		return domain.InspectionAnswer{QuestionID: q.ID, Verdict: verdict, Value: value, CreatedAt: at}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		answers  []domain.InspectionAnswer
+		expected []int
+	}{
+		{
+			name:     "Conditional questions hidden before the answer",
+			answers:  nil,
+			expected: []int{1, 4},
+		},
+		{
+			name:     "Condition not met",
+			answers:  []domain.InspectionAnswer{answer(filters, domain.VerdictPass, &domain.AnswerValue{Bool: &yes}, now)},
+			expected: []int{1, 4},
+		},
+		{
+			name:     "Condition met reveals the question",
+			answers:  []domain.InspectionAnswer{answer(filters, domain.VerdictFail, &domain.AnswerValue{Bool: &no}, now)},
+			expected: []int{1, 2, 4},
+		},
+		{
+			name: "Chained condition",
+			answers: []domain.InspectionAnswer{
+				answer(filters, domain.VerdictFail, &domain.AnswerValue{Bool: &no}, now),
+				answer(why, domain.VerdictFail, &domain.AnswerValue{Text: "forgot"}, now),
+			},
+			expected: []int{1, 2, 3, 4},
+		},
+		{
+			name: "Hidden parent hides the chain",
+			answers: []domain.InspectionAnswer{
+				answer(filters, domain.VerdictFail, &domain.AnswerValue{Bool: &no}, now),
+				answer(why, domain.VerdictFail, &domain.AnswerValue{Text: "forgot"}, now),
+				answer(filters, domain.VerdictPass, &domain.AnswerValue{Bool: &yes}, now.Add(time.Minute)),
+			},
+			expected: []int{1, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, q := range visibleQuestions(questions, tt.answers) {
+				got = append(got, q.Order)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateTemplateQuestions(t *testing.T) {
+	yes := true
+
+	tests := []struct {
+		name      string
+		showIf    *domain.QuestionCondition
+		wantError bool
+	}{
+		{name: "Condition on earlier yes/no question", showIf: &domain.QuestionCondition{Question: 1, Bool: &yes}},
+		{name: "Condition on earlier verdict", showIf: &domain.QuestionCondition{Question: 2, Verdicts: []domain.Verdict{domain.VerdictFail}}},
+		{name: "Condition on a later question", showIf: &domain.QuestionCondition{Question: 3, Verdicts: []domain.Verdict{domain.VerdictFail}}, wantError: true},
+		{name: "Empty condition", showIf: &domain.QuestionCondition{Question: 1}, wantError: true},
+		{name: "Yes/no condition on photo question", showIf: &domain.QuestionCondition{Question: 2, Bool: &yes}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions := []domain.Question{
+				{Order: 1, Type: domain.QuestionYesNo},
+				{Order: 2, Type: domain.QuestionPhoto},
+				{Order: 3, Type: domain.QuestionText, ShowIf: tt.showIf},
+			}
+			err := validateTemplateQuestions(questions)
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"MVP_checklist/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ReviewInspection approves a completed inspection or returns it to the
+// inspector with a remark on each answer to redo. Returning reopens the
+// inspection; it awaits review again once the inspector completes it.
+func (u *InspectionUseCase) ReviewInspection(ctx context.Context, inspectionID uuid.UUID, reviewer *domain.AdminUser, decision domain.ReviewDecision, comment string, rework []domain.ReworkRequest) error {
+	inspection, err := u.repo.GetInspectionByID(ctx, inspectionID)
+	if err != nil {
+		return err
+	}
+	if !inspection.AwaitingReview() {
+		return &domain.ValidationError{Message: "Проверка не ожидает решения"}
+	}
+
+	questions, answers, err := u.questionsAndAnswers(ctx, inspection)
+	if err != nil {
+		return err
+	}
+	rework, err = validateReview(decision, rework, visibleQuestions(questions, answers), answers)
+	if err != nil {
+		return err
+	}
+
+	review := &domain.InspectionReview{
+		ID:           uuid.New(),
+		InspectionID: inspectionID,
+		Decision:     decision,
+		ReviewerID:   reviewer.ID,
+		ReviewerName: reviewer.Name,
+		Comment:      strings.TrimSpace(comment),
+		Rework:       rework,
+		CreatedAt:    time.Now(),
+	}
+	if err := u.repo.ReviewInspection(ctx, review); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return &domain.ValidationError{Message: "Проверка не ожидает решения"}
+		}
+		return fmt.Errorf("failed to save review: %w", err)
+	}
+	recordAudit(ctx, u.repo, domain.AuditInspectionReview, domain.AuditEntityInspection, inspectionID.String(), inspection, review)
+	return nil
+}
+
+// validateReview checks the decision and returns the rework requests in
+// question order with trimmed comments. Only answered questions still asked
+// can be sent back, and returning an inspection requires at least one of them.
+func validateReview(decision domain.ReviewDecision, rework []domain.ReworkRequest, visible []domain.Question, answers []domain.InspectionAnswer) ([]domain.ReworkRequest, error) {
+	switch decision {
+	case domain.ReviewApproved:
+		if len(rework) > 0 {
+			return nil, &domain.ValidationError{Message: "Замечания к ответам указываются только при возврате на доработку"}
+		}
+		return nil, nil
+	case domain.ReviewReturned:
+		if len(rework) == 0 {
+			return nil, &domain.ValidationError{Message: "Укажите замечание хотя бы к одному ответу"}
+		}
+	default:
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Неизвестное решение %q", decision)}
+	}
+
+	comments := make(map[uuid.UUID]string, len(rework))
+	for _, r := range rework {
+		if _, ok := comments[r.QuestionID]; ok {
+			return nil, &domain.ValidationError{Message: "К вопросу указано несколько замечаний"}
+		}
+		comment := strings.TrimSpace(r.Comment)
+		if comment == "" {
+			return nil, &domain.ValidationError{Message: "Замечание к ответу не может быть пустым"}
+		}
+		comments[r.QuestionID] = comment
+	}
+
+	latest := latestAnswers(answers)
+	ordered := make([]domain.ReworkRequest, 0, len(rework))
+	for _, q := range visible {
+		comment, ok := comments[q.ID]
+		if !ok {
+			continue
+		}
+		if _, answered := latest[q.ID]; !answered {
+			break
+		}
+		ordered = append(ordered, domain.ReworkRequest{QuestionID: q.ID, Comment: comment})
+	}
+	if len(ordered) != len(comments) {
+		return nil, &domain.ValidationError{Message: "Замечание относится к вопросу без ответа"}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateReview(t *testing.T) {
+	q1 := domain.Question{ID: uuid.New(), Order: 1}
+	q2 := domain.Question{ID: uuid.New(), Order: 2}
+	q3 := domain.Question{ID: uuid.New(), Order: 3}
+	visible := []domain.Question{q1, q2, q3}
+	answers := []domain.InspectionAnswer{{QuestionID: q1.ID}, {QuestionID: q2.ID}}
+	rework := func(q domain.Question, comment string) domain.ReworkRequest {
+		return domain.ReworkRequest{QuestionID: q.ID, Comment: comment}
+	}
+
+	tests := []struct {
+		name      string
+		decision  domain.ReviewDecision
+		rework    []domain.ReworkRequest
+		expected  []domain.ReworkRequest
+		wantError bool
+	}{
+		{name: "Approve", decision: domain.ReviewApproved},
+		{name: "Approve with remarks", decision: domain.ReviewApproved, rework: []domain.ReworkRequest{rework(q1, "blurry")}, wantError: true},
+		{name: "Return without remarks", decision: domain.ReviewReturned, wantError: true},
+		{name: "Return in question order", decision: domain.ReviewReturned,
+			rework:   []domain.ReworkRequest{rework(q2, " wrong value "), rework(q1, "blurry")},
+			expected: []domain.ReworkRequest{rework(q1, "blurry"), rework(q2, "wrong value")}},
+		{name: "Empty remark", decision: domain.ReviewReturned, rework: []domain.ReworkRequest{rework(q1, "  ")}, wantError: true},
+		{name: "Unanswered question", decision: domain.ReviewReturned, rework: []domain.ReworkRequest{rework(q3, "missing")}, wantError: true},
+		{name: "Hidden question", decision: domain.ReviewReturned, rework: []domain.ReworkRequest{{QuestionID: uuid.New(), Comment: "x"}}, wantError: true},
+		{name: "Duplicate remark", decision: domain.ReviewReturned, rework: []domain.ReworkRequest{rework(q1, "a"), rework(q1, "b")}, wantError: true},
+		{name: "Unknown decision", decision: "maybe", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateReview(tt.decision, tt.rework, visible, answers)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("expected error: %v, got %v", tt.wantError, err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected %v, got %v", tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNeedsRework(t *testing.T) {
+	requested := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		answer   domain.InspectionAnswer
+		expected bool
+	}{
+		{name: "Not sent back", answer: domain.InspectionAnswer{UpdatedAt: requested}, expected: false},
+		{name: "Sent back", answer: domain.InspectionAnswer{UpdatedAt: requested.Add(-time.Hour), ReworkRequestedAt: &requested}, expected: true},
+		{name: "Saved again", answer: domain.InspectionAnswer{UpdatedAt: requested.Add(time.Minute), ReworkRequestedAt: &requested}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.answer.NeedsRework(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
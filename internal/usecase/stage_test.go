@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"MVP_checklist/internal/domain"
+)
+
+func TestMissingStages(t *testing.T) {
+	completed := func(role domain.Role, verdict domain.Verdict) domain.MachineInspection {
+		return domain.MachineInspection{
+			Inspection: domain.Inspection{Status: domain.StatusCompleted, Verdict: verdict},
+			Role:       role,
+		}
+	}
+	inProgress := func(role domain.Role) domain.MachineInspection {
+		return domain.MachineInspection{
+			Inspection: domain.Inspection{Status: domain.StatusInProgress},
+			Role:       role,
+		}
+	}
+
+	prerequisites := domain.DefaultStagePipeline.Prerequisites(domain.RoleOTK)
+
+	tests := []struct {
+		name     string
+		history  []domain.MachineInspection
+		expected []domain.Role
+	}{
+		{
+			name:     "Never inspected",
+			history:  nil,
+			expected: []domain.Role{domain.RoleAssembler, domain.RoleSticker, domain.RoleAds},
+		},
+		{
+			name: "All stages passed",
+			history: []domain.MachineInspection{
+				completed(domain.RoleAssembler, domain.VerdictPass),
+				completed(domain.RoleSticker, domain.VerdictPass),
+				completed(domain.RoleAds, domain.VerdictPass),
+			},
+			expected: nil,
+		},
+		{
+			name: "Stage failed",
+			history: []domain.MachineInspection{
+				completed(domain.RoleAssembler, domain.VerdictPass),
+				completed(domain.RoleSticker, domain.VerdictFail),
+				completed(domain.RoleAds, domain.VerdictPass),
+			},
+			expected: []domain.Role{domain.RoleSticker},
+		},
+		{
+			name: "Failed stage passed on recheck",
+			history: []domain.MachineInspection{
+				completed(domain.RoleAssembler, domain.VerdictFail),
+				completed(domain.RoleAssembler, domain.VerdictPass),
+				completed(domain.RoleSticker, domain.VerdictPass),
+				completed(domain.RoleAds, domain.VerdictPass),
+			},
+			expected: nil,
+		},
+		{
+			name: "Legacy completed, no verdict",
+			history: []domain.MachineInspection{
+				completed(domain.RoleAssembler, ""),
+				completed(domain.RoleSticker, ""),
+				completed(domain.RoleAds, domain.VerdictFail),
+			},
+			expected: []domain.Role{domain.RoleAds},
+		},
+		{
+			name: "Stage still in progress",
+			history: []domain.MachineInspection{
+				completed(domain.RoleAssembler, domain.VerdictPass),
+				completed(domain.RoleSticker, domain.VerdictPass),
+				inProgress(domain.RoleAds),
+			},
+			expected: []domain.Role{domain.RoleAds},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := missingStages(prerequisites, tt.history)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected missing=%v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseStagePipeline(t *testing.T) {
+	pipeline, err := domain.ParseStagePipeline("")
+	if err != nil || !reflect.DeepEqual(pipeline, domain.DefaultStagePipeline) {
+		t.Errorf("Expected default pipeline, got %v (err=%v)", pipeline, err)
+	}
+
+	pipeline, err = domain.ParseStagePipeline("assembler, OTK")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prereq := pipeline.Prerequisites(domain.RoleOTK); !reflect.DeepEqual(prereq, []domain.Role{domain.RoleAssembler}) {
+		t.Errorf("Expected OTK to require ASSEMBLER, got %v", prereq)
+	}
+	if prereq := pipeline.Prerequisites(domain.RoleAds); prereq != nil {
+		t.Errorf("Expected no prerequisites outside of pipeline, got %v", prereq)
+	}
+
+	if _, err := domain.ParseStagePipeline("OTK,UNKNOWN"); err == nil {
+		t.Errorf("Expected error for unknown role")
+	}
+}
+
+func TestGrantStageOverrideValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   domain.Role
+		reason string
+	}{
+		{name: "Unknown role", role: domain.Role("PAINT"), reason: "Срочная отгрузка"},
+		{name: "Missing reason", role: domain.RoleOTK, reason: "  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMachineUseCase(nil).GrantStageOverride(context.Background(), "AB1", tt.role, tt.reason, "Петров")
+			var validationErr *domain.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"MVP_checklist/internal/domain"
+)
+
+const (
+	// cleanupBatchSize limits the storage objects deleted per sweep.
+	cleanupBatchSize = 100
+	// cleanupRetryDelay postpones objects that failed to delete, so the
+	// batch moves on to the ones behind them.
+	cleanupRetryDelay = time.Hour
+)
+
+// AbandonStaleInspections marks inspections idle for longer than the
+// configured timeout as abandoned and schedules their photos for deletion
+// once the retention period has passed. It returns the number of inspections
+// abandoned.
+func (u *InspectionUseCase) AbandonStaleInspections(ctx context.Context, now time.Time) (int, error) {
+	if u.cfg.IdleTimeout <= 0 {
+		return 0, nil
+	}
+	ids, err := u.repo.AbandonInspections(ctx, now.Add(-u.cfg.IdleTimeout), now.Add(u.cfg.PhotoRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to abandon stale inspections: %w", err)
+	}
+	for _, id := range ids {
+		recordAudit(ctx, u.repo, domain.AuditInspectionAbandon, domain.AuditEntityInspection, id.String(), nil, map[string]domain.InspectionStatus{"Status": domain.StatusAbandoned})
+	}
+	return len(ids), nil
+}
+
+// CleanupStorage deletes the storage objects whose scheduled deletion time
+// has come. Objects that fail to delete stay scheduled and are retried after
+// cleanupRetryDelay.
+func (u *InspectionUseCase) CleanupStorage(ctx context.Context, now time.Time) (int, error) {
+	keys, err := u.repo.ListDueStorageCleanup(ctx, now, cleanupBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list storage cleanup: %w", err)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if err := u.storage.Delete(ctx, "", key); err != nil {
+			log.Printf("Storage cleanup of %s failed: %v", key, err)
+			if err := u.repo.PostponeStorageCleanup(ctx, key, now.Add(cleanupRetryDelay)); err != nil {
+				return deleted, fmt.Errorf("failed to postpone cleanup of %s: %w", key, err)
+			}
+			continue
+		}
+		if err := u.repo.MarkStorageCleanedUp(ctx, key); err != nil {
+			return deleted, fmt.Errorf("failed to mark %s cleaned up: %w", key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// CleanupSessions deletes expired login sessions and returns how many.
+func (u *InspectionUseCase) CleanupSessions(ctx context.Context, now time.Time) (int64, error) {
+	n, err := u.repo.DeleteExpiredSessions(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return n, nil
+}
+
+// RunSweeper abandons stale inspections and cleans up storage, unused
+// uploads and expired sessions every interval until the context is
+// cancelled. A non-positive interval disables it.
+func (u *InspectionUseCase) RunSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if n, err := u.AbandonStaleInspections(ctx, now); err != nil {
+			log.Printf("Sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("Sweeper: marked %d inspections abandoned", n)
+		}
+		if n, err := u.CleanupStorage(ctx, now); err != nil {
+			log.Printf("Sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("Sweeper: deleted %d stored photos", n)
+		}
+		if n, err := u.CleanupUploads(ctx, now); err != nil {
+			log.Printf("Sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("Sweeper: deleted %d unused photo uploads", n)
+		}
+		if n, err := u.CleanupSessions(ctx, now); err != nil {
+			log.Printf("Sweeper: %v", err)
+		} else if n > 0 {
+			log.Printf("Sweeper: deleted %d expired sessions", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
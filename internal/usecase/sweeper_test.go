@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"MVP_checklist/internal/domain"
+)
+
+// memStorage keeps objects in memory. Keys in failDelete cannot be deleted.
+type memStorage struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	failDelete map[string]bool
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: make(map[string][]byte), failDelete: make(map[string]bool)}
+}
+
+func (s *memStorage) Upload(ctx context.Context, bucket, key string, r io.Reader, size int64, contentType string) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return "", fmt.Errorf("expected %d bytes, got %d", size, len(data))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return key, nil
+}
+
+func (s *memStorage) GetURL(ctx context.Context, bucket, key string) (string, error) {
+	return "/uploads/" + key, nil
+}
+
+func (s *memStorage) Open(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", key, domain.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memStorage) Stat(ctx context.Context, bucket, key string) (*domain.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", key, domain.ErrNotFound)
+	}
+	return &domain.ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failDelete[key] {
+		return errors.New("access denied")
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+// cleanupRepo is a storage cleanup schedule: key to deletion time.
+type cleanupRepo struct {
+	domain.ChecklistRepository
+	due     map[string]time.Time
+	cleaned []string
+}
+
+func (r *cleanupRepo) ListDueStorageCleanup(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	var keys []string
+	for key, after := range r.due {
+		if !after.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *cleanupRepo) MarkStorageCleanedUp(ctx context.Context, key string) error {
+	delete(r.due, key)
+	r.cleaned = append(r.cleaned, key)
+	return nil
+}
+
+func (r *cleanupRepo) PostponeStorageCleanup(ctx context.Context, key string, until time.Time) error {
+	r.due[key] = until
+	return nil
+}
+
+func TestCleanupStorage(t *testing.T) {
+	now := time.Now()
+	storage := newMemStorage()
+	storage.failDelete["locked.jpg"] = true
+	repo := &cleanupRepo{due: map[string]time.Time{
+		"locked.jpg": now.Add(-time.Hour),
+		"photo.jpg":  now.Add(-time.Minute),
+		"later.jpg":  now.Add(time.Hour),
+	}}
+	uc := NewInspectionUseCase(repo, storage, InspectionConfig{})
+
+	deleted, err := uc.CleanupStorage(context.Background(), now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 1 || !reflect.DeepEqual(repo.cleaned, []string{"photo.jpg"}) {
+		t.Errorf("expected photo.jpg cleaned up, got %d %v", deleted, repo.cleaned)
+	}
+	if got, want := repo.due["locked.jpg"], now.Add(cleanupRetryDelay); !got.Equal(want) {
+		t.Errorf("expected failed deletion postponed to %v, got %v", want, got)
+	}
+	if _, ok := repo.due["later.jpg"]; !ok {
+		t.Error("expected object not yet due to stay scheduled")
+	}
+}
@@ -1,28 +1,50 @@
 package usecase
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
 
 	"MVP_checklist/internal/domain"
 	"github.com/google/uuid"
 )
 
 type TemplateUseCase struct {
-	repo domain.ChecklistRepository
+	repo    domain.ChecklistRepository
+	storage domain.FileStorage
 }
 
-func NewTemplateUseCase(repo domain.ChecklistRepository) *TemplateUseCase {
-	return &TemplateUseCase{repo: repo}
+// NewTemplateUseCase creates the template use case. Storage is only needed
+// for reference images and may be nil for tools that just write templates.
+func NewTemplateUseCase(repo domain.ChecklistRepository, storage domain.FileStorage) *TemplateUseCase {
+	return &TemplateUseCase{repo: repo, storage: storage}
 }
 
 func (u *TemplateUseCase) CreateTemplate(ctx context.Context, role domain.Role, questions []domain.Question) (*domain.ChecklistTemplate, error) {
-	// Find current version to increment
+	if err := validateTemplateQuestions(questions); err != nil {
+		return nil, err
+	}
+
+	// Increment the latest version, which after a rollback is not the active one
+	versions, err := u.repo.ListTemplatesByRole(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template versions: %w", err)
+	}
 	version := 1
-	oldTmpl, err := u.repo.GetTemplateByRole(ctx, role)
-	if err == nil && oldTmpl != nil {
-		version = oldTmpl.Version + 1
+	if len(versions) > 0 {
+		version = versions[0].Version + 1
 		// Deactivate old versions
 		_ = u.repo.DeactivateTemplatesByRole(ctx, role)
+		for _, v := range versions {
+			if v.IsActive {
+				recordDeactivation(ctx, u.repo, v)
+			}
+		}
 	}
 
 	template := &domain.ChecklistTemplate{
@@ -44,9 +66,22 @@ func (u *TemplateUseCase) CreateTemplate(ctx context.Context, role domain.Role,
 		}
 	}
 
+	recordAudit(ctx, u.repo, domain.AuditTemplateCreate, domain.AuditEntityTemplate, template.ID.String(), nil, templateSnapshot{template, questions})
 	return template, nil
 }
 
+// templateSnapshot is the audit log form of a template with its questions.
+type templateSnapshot struct {
+	Template  *domain.ChecklistTemplate
+	Questions []domain.Question
+}
+
+func recordDeactivation(ctx context.Context, repo domain.ChecklistRepository, template domain.ChecklistTemplate) {
+	after := template
+	after.IsActive = false
+	recordAudit(ctx, repo, domain.AuditTemplateDeactivate, domain.AuditEntityTemplate, template.ID.String(), template, after)
+}
+
 func (u *TemplateUseCase) ListTemplates(ctx context.Context) ([]domain.ChecklistTemplate, error) {
 	return u.repo.ListTemplates(ctx)
 }
@@ -65,6 +100,194 @@ func (u *TemplateUseCase) GetTemplateByRole(ctx context.Context, role domain.Rol
 	return template, questions, nil
 }
 
+// DeleteTemplateByRole deletes every version of the role's template. A
+// template that inspections were performed with cannot be deleted.
 func (u *TemplateUseCase) DeleteTemplateByRole(ctx context.Context, role domain.Role) error {
-	return u.repo.DeleteTemplateByRole(ctx, role)
+	inspections, err := u.repo.ListInspections(ctx, domain.InspectionFilter{Role: &role})
+	if err != nil {
+		return fmt.Errorf("failed to list inspections: %w", err)
+	}
+	if len(inspections) > 0 {
+		return &domain.ValidationError{Message: fmt.Sprintf("По шаблону «%s» уже проводились проверки, его нельзя удалить", role.Title())}
+	}
+
+	versions, err := u.repo.ListTemplatesByRole(ctx, role)
+	if err != nil {
+		return fmt.Errorf("failed to list template versions: %w", err)
+	}
+	if err := u.repo.DeleteTemplateByRole(ctx, role); err != nil {
+		return err
+	}
+	recordAudit(ctx, u.repo, domain.AuditTemplateDelete, domain.AuditEntityTemplate, string(role), versions, nil)
+	return nil
+}
+
+// GetTemplateForEditing returns the questions of the active template of the
+// role, or none for a role without a template, along with viewable URLs of
+// their reference images keyed by storage key.
+func (u *TemplateUseCase) GetTemplateForEditing(ctx context.Context, role domain.Role) (*domain.ChecklistTemplate, []domain.Question, map[string]string, error) {
+	template, questions, err := u.GetTemplateByRole(ctx, role)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, nil, err
+	}
+
+	urls := make(map[string]string)
+	for _, q := range questions {
+		for _, key := range q.ReferenceImages {
+			url, err := u.storage.GetURL(ctx, "", key)
+			if err != nil {
+				url = key
+			}
+			urls[key] = url
+		}
+	}
+	return template, questions, urls, nil
+}
+
+// referenceImageTypes maps the accepted reference image content types to file extensions.
+var referenceImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// UploadReferenceImage streams a reference image for a template question to
+// storage and returns its storage key and a URL to show it.
+func (u *TemplateUseCase) UploadReferenceImage(ctx context.Context, r io.Reader) (string, string, error) {
+	image := bufio.NewReader(r)
+	head, err := image.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", "", fmt.Errorf("failed to read reference image: %w", err)
+	}
+	contentType := http.DetectContentType(head)
+	ext, ok := referenceImageTypes[contentType]
+	if !ok {
+		return "", "", &domain.ValidationError{Message: "Загрузите изображение в формате JPEG, PNG или WebP"}
+	}
+
+	key, err := u.storage.Upload(ctx, "", "refs/"+uuid.New().String()+ext, image, -1, contentType)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload reference image: %w", err)
+	}
+	url, err := u.storage.GetURL(ctx, "", key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get reference image url: %w", err)
+	}
+	return key, url, nil
+}
+
+// ListVersions returns every version of the role's template, newest first.
+func (u *TemplateUseCase) ListVersions(ctx context.Context, role domain.Role) ([]domain.ChecklistTemplate, error) {
+	return u.repo.ListTemplatesByRole(ctx, role)
+}
+
+// DiffTemplates compares the questions of two versions of a template.
+func (u *TemplateUseCase) DiffTemplates(ctx context.Context, fromID, toID uuid.UUID) (*domain.TemplateDiff, error) {
+	from, err := u.repo.GetTemplateByID(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := u.repo.GetTemplateByID(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldQuestions, err := u.repo.GetQuestionsByTemplateID(ctx, from.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questions of v%d: %w", from.Version, err)
+	}
+	newQuestions, err := u.repo.GetQuestionsByTemplateID(ctx, to.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questions of v%d: %w", to.Version, err)
+	}
+
+	return &domain.TemplateDiff{
+		From:    *from,
+		To:      *to,
+		Changes: diffQuestions(oldQuestions, newQuestions),
+	}, nil
+}
+
+// RollbackTemplate makes an earlier version the active one again. Later
+// versions are kept and can be activated the same way.
+func (u *TemplateUseCase) RollbackTemplate(ctx context.Context, id uuid.UUID) (*domain.ChecklistTemplate, error) {
+	template, err := u.repo.GetTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := u.repo.GetTemplateByRole(ctx, template.Role)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get active template: %w", err)
+	}
+	if err := u.repo.ActivateTemplate(ctx, template.Role, template.ID); err != nil {
+		return nil, fmt.Errorf("failed to activate template: %w", err)
+	}
+
+	if previous != nil && previous.ID != template.ID {
+		recordDeactivation(ctx, u.repo, *previous)
+	}
+	before := *template
+	template.IsActive = true
+	recordAudit(ctx, u.repo, domain.AuditTemplateActivate, domain.AuditEntityTemplate, template.ID.String(), before, template)
+	return template, nil
+}
+
+// diffQuestions matches questions of two versions and reports the
+// differences. Questions are matched by identical text first; the rest are
+// matched by position and reported as reworded.
+func diffQuestions(oldQuestions, newQuestions []domain.Question) []domain.QuestionChange {
+	oldMatched := make([]bool, len(oldQuestions))
+	newMatch := make([]int, len(newQuestions))
+	for i := range newMatch {
+		newMatch[i] = -1
+	}
+
+	for i, nq := range newQuestions {
+		for j, oq := range oldQuestions {
+			if !oldMatched[j] && oq.Text == nq.Text {
+				oldMatched[j], newMatch[i] = true, j
+				break
+			}
+		}
+	}
+	for i, nq := range newQuestions {
+		if newMatch[i] >= 0 {
+			continue
+		}
+		for j, oq := range oldQuestions {
+			if !oldMatched[j] && oq.Order == nq.Order {
+				oldMatched[j], newMatch[i] = true, j
+				break
+			}
+		}
+	}
+
+	var changes []domain.QuestionChange
+	for j := range oldQuestions {
+		if !oldMatched[j] {
+			changes = append(changes, domain.QuestionChange{Kind: domain.QuestionRemoved, Old: &oldQuestions[j]})
+		}
+	}
+	for i := range newQuestions {
+		if newMatch[i] < 0 {
+			changes = append(changes, domain.QuestionChange{Kind: domain.QuestionAdded, New: &newQuestions[i]})
+			continue
+		}
+		oq, nq := &oldQuestions[newMatch[i]], &newQuestions[i]
+		change := domain.QuestionChange{
+			Kind:              domain.QuestionChanged,
+			Old:               oq,
+			New:               nq,
+			TextChanged:       oq.Text != nq.Text,
+			OrderChanged:      oq.Order != nq.Order,
+			TypeChanged:       oq.Type != nq.Type || !reflect.DeepEqual(oq.Config, nq.Config) || !reflect.DeepEqual(oq.ShowIf, nq.ShowIf),
+			PhotosChanged:     oq.MinPhotos != nq.MinPhotos || oq.MaxPhotos != nq.MaxPhotos,
+			RequiredChanged:   oq.IsRequired != nq.IsRequired,
+			ReferencesChanged: !slices.Equal(oq.ReferenceImages, nq.ReferenceImages),
+		}
+		if change.TextChanged || change.OrderChanged || change.TypeChanged || change.PhotosChanged || change.RequiredChanged || change.ReferencesChanged {
+			changes = append(changes, change)
+		}
+	}
+	return changes
 }
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"MVP_checklist/internal/domain"
+)
+
+// TemplateFile is the declarative form of a checklist template kept in the
+// repository. Questions are numbered by their position, starting at 1, and
+// show-if conditions refer to that number.
+type TemplateFile struct {
+	Role      domain.Role            `json:"role"`
+	Questions []TemplateFileQuestion `json:"questions"`
+}
+
+type TemplateFileQuestion struct {
+	Text            string                    `json:"text"`
+	Type            domain.QuestionType       `json:"type,omitempty"`
+	Config          *domain.QuestionConfig    `json:"config,omitempty"`
+	ShowIf          *domain.QuestionCondition `json:"show_if,omitempty"`
+	MinPhotos       int                       `json:"min_photos"`
+	MaxPhotos       int                       `json:"max_photos"`
+	IsRequired      bool                      `json:"required"`
+	ReferenceImages []string                  `json:"reference_images,omitempty"`
+}
+
+// ParseTemplateFile decodes and validates a template file.
+func ParseTemplateFile(data []byte) (*TemplateFile, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var file TemplateFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode template file: %w", err)
+	}
+	if !file.Role.IsValid() {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Неизвестная роль %q", file.Role)}
+	}
+	if len(file.Questions) == 0 {
+		return nil, &domain.ValidationError{Message: "В шаблоне нет вопросов"}
+	}
+	for i, q := range file.Questions {
+		if strings.TrimSpace(q.Text) == "" {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Вопрос %d: пустой текст", i+1)}
+		}
+	}
+	if err := validateTemplateQuestions(file.questions()); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// LoadTemplateFiles parses every *.json template file in dir. A role may be
+// defined only once.
+func LoadTemplateFiles(dir string) ([]TemplateFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template files: %w", err)
+	}
+
+	var files []TemplateFile
+	seen := make(map[domain.Role]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		file, err := ParseTemplateFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if other, ok := seen[file.Role]; ok {
+			return nil, fmt.Errorf("%s: role %s is already defined in %s", path, file.Role, other)
+		}
+		seen[file.Role] = path
+		files = append(files, *file)
+	}
+	return files, nil
+}
+
+// questions converts the file into template questions ready to be created.
+func (f *TemplateFile) questions() []domain.Question {
+	questions := make([]domain.Question, len(f.Questions))
+	for i, q := range f.Questions {
+		questions[i] = domain.Question{
+			Text:            q.Text,
+			Type:            q.Type,
+			ShowIf:          q.ShowIf,
+			Order:           i + 1,
+			MinPhotos:       q.MinPhotos,
+			MaxPhotos:       q.MaxPhotos,
+			IsRequired:      q.IsRequired,
+			ReferenceImages: slices.Clone(q.ReferenceImages),
+		}
+		if q.Config != nil {
+			questions[i].Config = *q.Config
+		}
+		if questions[i].ReferenceImages == nil {
+			questions[i].ReferenceImages = []string{}
+		}
+	}
+	return questions
+}
+
+// templateFileFromQuestions builds the file form of stored template
+// questions, renumbering show-if references by position.
+func templateFileFromQuestions(role domain.Role, questions []domain.Question) TemplateFile {
+	position := make(map[int]int, len(questions))
+	for i, q := range questions {
+		position[q.Order] = i + 1
+	}
+
+	file := TemplateFile{Role: role, Questions: make([]TemplateFileQuestion, len(questions))}
+	for i, q := range questions {
+		fq := TemplateFileQuestion{
+			Text:            q.Text,
+			Type:            q.Type,
+			MinPhotos:       q.MinPhotos,
+			MaxPhotos:       q.MaxPhotos,
+			IsRequired:      q.IsRequired,
+			ReferenceImages: q.ReferenceImages,
+		}
+		if fq.Type == "" {
+			fq.Type = domain.QuestionPhoto
+		}
+		if c := q.Config; c.Min != nil || c.Max != nil || c.Unit != "" || len(c.Options) > 0 || len(c.FailOptions) > 0 || c.PhotoQuality != nil {
+			fq.Config = &c
+		}
+		if q.ShowIf != nil {
+			showIf := *q.ShowIf
+			showIf.Question = position[showIf.Question]
+			fq.ShowIf = &showIf
+		}
+		file.Questions[i] = fq
+	}
+	return file
+}
+
+// Marshal encodes the file in the layout used by the checklists directory.
+func (f *TemplateFile) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode template file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Hash returns a digest of the template content. Files that differ only in
+// formatting hash the same.
+func (f *TemplateFile) Hash() string {
+	normalized := templateFileFromQuestions(f.Role, f.questions())
+	data, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ImportTemplate creates a new version of the role's template from the file
+// unless the active template already has the same content. It reports
+// whether a version was created.
+func (u *TemplateUseCase) ImportTemplate(ctx context.Context, file TemplateFile) (*domain.ChecklistTemplate, bool, error) {
+	active, questions, err := u.GetTemplateByRole(ctx, file.Role)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, false, fmt.Errorf("failed to get active template: %w", err)
+	}
+	if active != nil {
+		current := templateFileFromQuestions(file.Role, questions)
+		if current.Hash() == file.Hash() {
+			return active, false, nil
+		}
+	}
+
+	template, err := u.CreateTemplate(ctx, file.Role, file.questions())
+	if err != nil {
+		return nil, false, err
+	}
+	return template, true, nil
+}
+
+// ExportTemplates returns the active template of every role in file form.
+func (u *TemplateUseCase) ExportTemplates(ctx context.Context) ([]TemplateFile, error) {
+	templates, err := u.repo.ListTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	var files []TemplateFile
+	var exported []domain.ChecklistTemplate
+	for _, t := range templates {
+		if !t.IsActive {
+			continue
+		}
+		questions, err := u.repo.GetQuestionsByTemplateID(ctx, t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get questions of %s: %w", t.Role, err)
+		}
+		files = append(files, templateFileFromQuestions(t.Role, questions))
+		exported = append(exported, t)
+	}
+	for _, t := range exported {
+		recordAudit(ctx, u.repo, domain.AuditTemplateExport, domain.AuditEntityTemplate, t.ID.String(), nil, nil)
+	}
+	return files, nil
+}
+
+// ExportTemplate returns the active template of the role in file form.
+func (u *TemplateUseCase) ExportTemplate(ctx context.Context, role domain.Role) (*TemplateFile, error) {
+	template, questions, err := u.GetTemplateByRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	file := templateFileFromQuestions(role, questions)
+	recordAudit(ctx, u.repo, domain.AuditTemplateExport, domain.AuditEntityTemplate, template.ID.String(), nil, nil)
+	return &file, nil
+}
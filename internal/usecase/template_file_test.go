@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"testing"
+
+	"MVP_checklist/internal/domain"
+)
+
+func TestParseTemplateFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantError bool
+	}{
+		{
+			name: "Photo question",
+			data: `{"role": "OTK", "questions": [{"text": "Displays are on", "min_photos": 1, "max_photos": 5, "required": true}]}`,
+		},
+		{
+			name: "Condition on earlier question",
+			data: `{"role": "OTK", "questions": [
+				{"text": "Filters removed?", "type": "yes_no", "min_photos": 0, "max_photos": 0},
+				{"text": "Why?", "type": "text", "show_if": {"question": 1, "bool": false}, "min_photos": 0, "max_photos": 1}
+			]}`,
+		},
+		{
+			name:      "Unknown role",
+			data:      `{"role": "PAINTER", "questions": [{"text": "Paint is dry", "min_photos": 1, "max_photos": 5}]}`,
+			wantError: true,
+		},
+		{
+			name:      "Unknown field",
+			data:      `{"role": "OTK", "questions": [{"text": "Displays are on", "photos": 1}]}`,
+			wantError: true,
+		},
+		{
+			name:      "No questions",
+			data:      `{"role": "OTK", "questions": []}`,
+			wantError: true,
+		},
+		{
+			name:      "Max photos below min",
+			data:      `{"role": "OTK", "questions": [{"text": "Displays are on", "min_photos": 2, "max_photos": 1}]}`,
+			wantError: true,
+		},
+		{
+			name:      "Invalid question config",
+			data:      `{"role": "OTK", "questions": [{"text": "Voltage", "type": "number", "min_photos": 0, "max_photos": 0}]}`,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplateFile([]byte(tt.data))
+			if (err != nil) != tt.wantError {
+				t.Errorf("expected error: %v, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestTemplateFileHash(t *testing.T) {
+	file, err := ParseTemplateFile([]byte(`{"role": "OTK", "questions": [
+		{"text": "Filters removed?", "type": "yes_no", "min_photos": 0, "max_photos": 0, "required": true},
+		{"text": "Displays are on", "min_photos": 1, "max_photos": 5, "required": true, "reference_images": ["refs/otk.jpg"]},
+		{"text": "Why?", "type": "text", "show_if": {"question": 1, "bool": false}, "min_photos": 0, "max_photos": 1}
+	]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored := file.questions()
+	for i := range stored {
+		// Stored orders may have gaps; show-if references follow them
+		stored[i].Order = (i + 1) * 10
+	}
+	stored[2].ShowIf = &domain.QuestionCondition{Question: 10, Bool: stored[2].ShowIf.Bool}
+
+	tests := []struct {
+		name     string
+		modify   func(questions []domain.Question)
+		expected bool
+	}{
+		{name: "Same content", modify: func([]domain.Question) {}, expected: true},
+		{name: "Changed text", modify: func(q []domain.Question) { q[1].Text = "Displays are bright" }, expected: false},
+		{name: "Changed photo limit", modify: func(q []domain.Question) { q[1].MaxPhotos = 3 }, expected: false},
+		{name: "Removed reference", modify: func(q []domain.Question) { q[1].ReferenceImages = []string{} }, expected: false},
+		{name: "Changed condition", modify: func(q []domain.Question) {
+			q[2].ShowIf = &domain.QuestionCondition{Question: 20, Verdicts: []domain.Verdict{domain.VerdictFail}}
+		}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions := make([]domain.Question, len(stored))
+			copy(questions, stored)
+			tt.modify(questions)
+
+			current := templateFileFromQuestions(domain.RoleOTK, questions)
+			if got := current.Hash() == file.Hash(); got != tt.expected {
+				t.Errorf("expected same hash: %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestChecklistFiles(t *testing.T) {
+	files, err := LoadTemplateFiles("../../checklists")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != len(domain.DefaultStagePipeline) {
+		t.Errorf("expected a file per role, got %d", len(files))
+	}
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"testing"
+
+	"MVP_checklist/internal/domain"
+)
+
+func TestDiffQuestions(t *testing.T) {
+	question := func(order int, text string) domain.Question {
+		return domain.Question{Order: order, Text: text, Type: domain.QuestionPhoto, MinPhotos: 1, MaxPhotos: 5, IsRequired: true}
+	}
+	shelves := question(1, "Shelves are fixed")
+	springs := question(2, "Springs are aligned")
+	displays := question(3, "Displays are on")
+
+	tests := []struct {
+		name     string
+		old      []domain.Question
+		new      func() []domain.Question
+		expected []string
+	}{
+		{
+			name:     "Same questions",
+			old:      []domain.Question{shelves, springs},
+			new:      func() []domain.Question { return []domain.Question{shelves, springs} },
+			expected: nil,
+		},
+		{
+			name: "Added and removed",
+			old:  []domain.Question{shelves, springs},
+			new: func() []domain.Question {
+				return []domain.Question{shelves, question(3, "SIM card installed")}
+			},
+			expected: []string{"removed Springs are aligned", "added SIM card installed"},
+		},
+		{
+			name: "Reworded question keeps its position",
+			old:  []domain.Question{shelves, springs},
+			new: func() []domain.Question {
+				return []domain.Question{shelves, question(2, "Springs are aligned, logo visible")}
+			},
+			expected: []string{"changed text"},
+		},
+		{
+			name: "Moved question",
+			old:  []domain.Question{shelves, springs, displays},
+			new: func() []domain.Question {
+				d, s := displays, springs
+				d.Order, s.Order = 2, 3
+				return []domain.Question{shelves, d, s}
+			},
+			expected: []string{"changed order", "changed order"},
+		},
+		{
+			name: "Photo limits and references",
+			old:  []domain.Question{shelves},
+			new: func() []domain.Question {
+				q := shelves
+				q.MaxPhotos = 3
+				q.ReferenceImages = []string{"refs/shelves.jpg"}
+				return []domain.Question{q}
+			},
+			expected: []string{"changed photos references"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffQuestions(tt.old, tt.new())
+			if len(changes) != len(tt.expected) {
+				t.Fatalf("expected %d changes, got %d: %+v", len(tt.expected), len(changes), changes)
+			}
+			for i, c := range changes {
+				if got := describeChange(c); got != tt.expected[i] {
+					t.Errorf("change %d: expected %q, got %q", i, tt.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func describeChange(c domain.QuestionChange) string {
+	switch c.Kind {
+	case domain.QuestionAdded:
+		return "added " + c.New.Text
+	case domain.QuestionRemoved:
+		return "removed " + c.Old.Text
+	}
+	s := "changed"
+	for _, f := range []struct {
+		changed bool
+		name    string
+	}{
+		{c.TextChanged, "text"},
+		{c.OrderChanged, "order"},
+		{c.TypeChanged, "type"},
+		{c.PhotosChanged, "photos"},
+		{c.RequiredChanged, "required"},
+		{c.ReferencesChanged, "references"},
+	} {
+		if f.changed {
+			s += " " + f.name
+		}
+	}
+	return s
+}
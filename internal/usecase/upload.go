@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxPhotoUploadSize limits the size of one uploaded photo
+	maxPhotoUploadSize = 25 << 20
+	// MaxUploadChunkSize limits the bytes appended to an upload at once
+	MaxUploadChunkSize = 4 << 20
+	// UploadChunkPrefix is where the received chunks of uploads are stored;
+	// they are only served through the inspection they belong to
+	UploadChunkPrefix = "photo-uploads/"
+)
+
+// CreateUpload starts a resumable upload of a photo of size bytes to an
+// open inspection.
+func (u *InspectionUseCase) CreateUpload(ctx context.Context, inspection *domain.Inspection, size int64) (*domain.PhotoUpload, error) {
+	if err := checkOpen(inspection); err != nil {
+		return nil, err
+	}
+	if size <= 0 || size > maxPhotoUploadSize {
+		return nil, &domain.ValidationError{Message: fmt.Sprintf("Фото должно быть не больше %d МБ", maxPhotoUploadSize>>20)}
+	}
+
+	now := time.Now()
+	upload := &domain.PhotoUpload{ID: uuid.New(), InspectionID: inspection.ID, Size: size, CreatedAt: now, UpdatedAt: now}
+	if err := u.repo.CreatePhotoUpload(ctx, upload); err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	return upload, nil
+}
+
+// GetUpload returns an upload to the inspection; ErrNotFound for uploads to
+// other inspections.
+func (u *InspectionUseCase) GetUpload(ctx context.Context, inspectionID, uploadID uuid.UUID) (*domain.PhotoUpload, error) {
+	upload, err := u.repo.GetPhotoUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.InspectionID != inspectionID {
+		return nil, fmt.Errorf("upload %s: %w", uploadID, domain.ErrNotFound)
+	}
+	return upload, nil
+}
+
+// AppendUpload stores a chunk of size bytes read from r. A chunk that does
+// not start where the upload stands fails with ErrUploadOffset along with the
+// upload, so the client can resume from its offset; resending a chunk that
+// was already received is therefore harmless.
+func (u *InspectionUseCase) AppendUpload(ctx context.Context, inspectionID, uploadID uuid.UUID, offset int64, r io.Reader, size int64) (*domain.PhotoUpload, error) {
+	upload, err := u.GetUpload(ctx, inspectionID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return upload, domain.ErrUploadOffset
+	}
+	if offset+size > upload.Size {
+		return upload, &domain.ValidationError{Message: "Размер фото больше заявленного"}
+	}
+	if size <= 0 {
+		return upload, nil
+	}
+
+	key, err := u.storage.Upload(ctx, "", fmt.Sprintf("%s%s/%d-%s", UploadChunkPrefix, uploadID, offset, uuid.New()), r, size, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store upload chunk: %w", err)
+	}
+	upload.Offset, err = u.repo.AppendPhotoUpload(ctx, uploadID, domain.PhotoUploadChunk{Offset: offset, Size: size, Key: key})
+	if err != nil {
+		// Nothing refers to a chunk that was not recorded
+		if deleteErr := u.storage.Delete(ctx, "", key); deleteErr != nil {
+			log.Printf("Failed to delete unrecorded upload chunk %s: %v", key, deleteErr)
+		}
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		// A concurrent request moved the upload on
+		if upload, err = u.GetUpload(ctx, inspectionID, uploadID); err != nil {
+			return nil, err
+		}
+		return upload, domain.ErrUploadOffset
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to append to upload: %w", err)
+	}
+	upload.UpdatedAt = time.Now()
+	return upload, nil
+}
+
+// OpenUpload streams the content of a complete upload; the caller closes it.
+func (u *InspectionUseCase) OpenUpload(ctx context.Context, inspectionID, uploadID uuid.UUID) (io.ReadCloser, *domain.PhotoUpload, error) {
+	upload, err := u.GetUpload(ctx, inspectionID, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !upload.Complete() {
+		return nil, nil, &domain.ValidationError{Message: "Фото загружено не полностью"}
+	}
+	content, err := u.openUpload(ctx, upload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, upload, nil
+}
+
+// openUpload streams the chunks of an upload as one file.
+func (u *InspectionUseCase) openUpload(ctx context.Context, upload *domain.PhotoUpload) (io.ReadCloser, error) {
+	chunks, err := u.repo.ListPhotoUploadChunks(ctx, upload.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload chunks: %w", err)
+	}
+	keys := make([]string, len(chunks))
+	for i, c := range chunks {
+		keys[i] = c.Key
+	}
+	return &uploadReader{ctx: ctx, storage: u.storage, keys: keys}, nil
+}
+
+// uploadReader reads stored chunks one after another, opening each only
+// once the previous one is used up.
+type uploadReader struct {
+	ctx     context.Context
+	storage domain.FileStorage
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			file, err := r.storage.Open(r.ctx, "", r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to open upload chunk: %w", err)
+			}
+			r.current, r.keys = file, r.keys[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *uploadReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
+
+// DeleteUpload discards an upload the inspector removed from the answer.
+func (u *InspectionUseCase) DeleteUpload(ctx context.Context, inspectionID, uploadID uuid.UUID) error {
+	if _, err := u.GetUpload(ctx, inspectionID, uploadID); err != nil {
+		return err
+	}
+	return u.repo.DeletePhotoUploads(ctx, []uuid.UUID{uploadID})
+}
+
+// uploadedPhotos returns the uploads an answer references, in order. Every
+// upload must be complete.
+func (u *InspectionUseCase) uploadedPhotos(ctx context.Context, inspectionID uuid.UUID, ids []uuid.UUID) ([]*domain.PhotoUpload, error) {
+	uploads := make([]*domain.PhotoUpload, len(ids))
+	for i, id := range ids {
+		upload, err := u.GetUpload(ctx, inspectionID, id)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Фото %d не найдено на сервере, добавьте его снова", i+1)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get upload: %w", err)
+		}
+		if !upload.Complete() {
+			return nil, &domain.ValidationError{Message: fmt.Sprintf("Фото %d загружено не полностью. Дождитесь окончания загрузки", i+1)}
+		}
+		uploads[i] = upload
+	}
+	return uploads, nil
+}
+
+// CleanupUploads deletes uploads no answer took up within the configured
+// retention. It returns the number of uploads deleted.
+func (u *InspectionUseCase) CleanupUploads(ctx context.Context, now time.Time) (int64, error) {
+	if u.cfg.UploadRetention <= 0 {
+		return 0, nil
+	}
+	n, err := u.repo.DeleteStalePhotoUploads(ctx, now.Add(-u.cfg.UploadRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale uploads: %w", err)
+	}
+	return n, nil
+}
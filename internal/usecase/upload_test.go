@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"MVP_checklist/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// racingRepo lets another request append to the upload right before the
+// append under test is recorded.
+type racingRepo struct {
+	*uploadRepo
+	race func()
+}
+
+func (r *racingRepo) AppendPhotoUpload(ctx context.Context, id uuid.UUID, chunk domain.PhotoUploadChunk) (int64, error) {
+	if r.race != nil {
+		race := r.race
+		r.race = nil
+		race()
+	}
+	return r.uploadRepo.AppendPhotoUpload(ctx, id, chunk)
+}
+
+func TestAppendUpload(t *testing.T) {
+	tests := []struct {
+		name       string
+		received   []string // chunks appended before
+		offset     int64
+		chunk      string
+		concurrent string // appended by another request meanwhile
+		wantErr    error
+		validation bool
+		offsetNow  int64
+		content    string
+	}{
+		{name: "First chunk", chunk: "hello", offsetNow: 5, content: "hello"},
+		{name: "Next chunk", received: []string{"hello"}, offset: 5, chunk: " worl", offsetNow: 10, content: "hello worl"},
+		{name: "Offset ahead of the upload", received: []string{"hello"}, offset: 8, chunk: "ld", wantErr: domain.ErrUploadOffset, offsetNow: 5, content: "hello"},
+		{name: "Replayed chunk", received: []string{"hello"}, offset: 0, chunk: "hello", wantErr: domain.ErrUploadOffset, offsetNow: 5, content: "hello"},
+		{name: "Larger than announced", received: []string{"hello"}, offset: 5, chunk: " world and more", validation: true, offsetNow: 5, content: "hello"},
+		{name: "Concurrent append", received: []string{"hello"}, offset: 5, chunk: " worl", concurrent: " WORL", wantErr: domain.ErrUploadOffset, offsetNow: 10, content: "hello WORL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			base := newUploadRepo()
+			repo := &racingRepo{uploadRepo: base}
+			storage := newMemStorage()
+			uc := NewInspectionUseCase(repo, storage, InspectionConfig{})
+			inspectionID := base.inspection.ID
+
+			upload, err := uc.CreateUpload(ctx, &base.inspection, 11)
+			if err != nil {
+				t.Fatalf("failed to create upload: %v", err)
+			}
+			var offset int64
+			for _, chunk := range tt.received {
+				if _, err := uc.AppendUpload(ctx, inspectionID, upload.ID, offset, strings.NewReader(chunk), int64(len(chunk))); err != nil {
+					t.Fatalf("failed to append %q: %v", chunk, err)
+				}
+				offset += int64(len(chunk))
+			}
+			if tt.concurrent != "" {
+				repo.race = func() {
+					if _, err := uc.AppendUpload(ctx, inspectionID, upload.ID, tt.offset, strings.NewReader(tt.concurrent), int64(len(tt.concurrent))); err != nil {
+						t.Fatalf("concurrent append failed: %v", err)
+					}
+				}
+			}
+
+			got, err := uc.AppendUpload(ctx, inspectionID, upload.ID, tt.offset, strings.NewReader(tt.chunk), int64(len(tt.chunk)))
+			var validationErr *domain.ValidationError
+			switch {
+			case tt.validation:
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("expected validation error, got %v", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got == nil || got.Offset != tt.offsetNow {
+				t.Errorf("expected upload at offset %d, got %+v", tt.offsetNow, got)
+			}
+
+			// Only recorded chunks are left in storage
+			chunks, _ := base.ListPhotoUploadChunks(ctx, upload.ID)
+			if len(storage.objects) != len(chunks) {
+				t.Errorf("expected %d stored chunks, got %d", len(chunks), len(storage.objects))
+			}
+			content, err := uc.openUpload(ctx, got)
+			if err != nil {
+				t.Fatalf("failed to open upload: %v", err)
+			}
+			defer content.Close()
+			data, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatalf("failed to read upload: %v", err)
+			}
+			if string(data) != tt.content {
+				t.Errorf("expected content %q, got %q", tt.content, data)
+			}
+		})
+	}
+}
+
+func TestUploadReader(t *testing.T) {
+	storage := newMemStorage()
+	for key, data := range map[string]string{"a": "Hello", "b": ", ", "c": "world"} {
+		storage.objects[key] = []byte(data)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		oneByte bool
+		want    string
+		wantErr bool
+	}{
+		{name: "Chunks in order", keys: []string{"a", "b", "c"}, want: "Hello, world"},
+		{name: "Read a byte at a time", keys: []string{"a", "b", "c"}, oneByte: true, want: "Hello, world"},
+		{name: "No chunks", want: ""},
+		{name: "Missing chunk", keys: []string{"a", "missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &uploadReader{ctx: context.Background(), storage: storage, keys: tt.keys}
+			defer reader.Close()
+			var r io.Reader = reader
+			if tt.oneByte {
+				r = iotest.OneByteReader(reader)
+			}
+			data, err := io.ReadAll(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && string(data) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+-- Migration: Pass / Fail / N/A verdicts for answers and inspections
+
+ALTER TABLE inspection_answers ADD COLUMN IF NOT EXISTS verdict VARCHAR(20);
+
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS verdict VARCHAR(20);
@@ -0,0 +1,25 @@
+-- Migration: Machine registry keyed by serial number
+
+CREATE TABLE IF NOT EXISTS machines (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    serial VARCHAR(100) NOT NULL UNIQUE,
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    production_order VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    shipped_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Register every serial that was already inspected
+INSERT INTO machines (serial, created_at)
+SELECT UPPER(TRIM(machine_serial)), MIN(started_at) FROM inspections GROUP BY UPPER(TRIM(machine_serial))
+ON CONFLICT (serial) DO NOTHING;
+
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS machine_id UUID REFERENCES machines(id);
+
+UPDATE inspections i SET machine_id = m.id
+FROM machines m
+WHERE i.machine_id IS NULL AND m.serial = UPPER(TRIM(i.machine_serial));
+
+ALTER TABLE inspections ALTER COLUMN machine_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_inspections_machine_id ON inspections(machine_id);
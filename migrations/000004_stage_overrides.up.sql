@@ -0,0 +1,15 @@
+-- Migration: Admin overrides for production stage gating
+
+CREATE TABLE IF NOT EXISTS stage_overrides (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    machine_id UUID NOT NULL REFERENCES machines(id),
+    role VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL,
+    granted_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each override can be used by a single inspection
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS stage_override_id UUID UNIQUE REFERENCES stage_overrides(id);
+
+CREATE INDEX IF NOT EXISTS idx_stage_overrides_machine_id ON stage_overrides(machine_id);
@@ -0,0 +1,28 @@
+-- Migration: Inspector accounts and login sessions
+
+CREATE TABLE IF NOT EXISTS inspectors (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    pin_hash TEXT NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inspectors_name ON inspectors (LOWER(name));
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash VARCHAR(64) PRIMARY KEY, -- SHA-256 of the cookie token
+    kind VARCHAR(20) NOT NULL,
+    subject_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Expired sessions are purged by the sweeper
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+-- inspector_name is kept: it is the only record of who performed older inspections
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS inspector_id UUID REFERENCES inspectors(id);
+
+CREATE INDEX IF NOT EXISTS idx_inspections_inspector_id ON inspections(inspector_id);
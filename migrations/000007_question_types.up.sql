@@ -0,0 +1,6 @@
+-- Migration: Typed questions and answer values
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS type VARCHAR(50) NOT NULL DEFAULT 'photo';
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS config JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE inspection_answers ADD COLUMN IF NOT EXISTS value JSONB;
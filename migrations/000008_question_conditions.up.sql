@@ -0,0 +1,3 @@
+-- Migration: Show-if conditions for questions
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS show_if JSONB;
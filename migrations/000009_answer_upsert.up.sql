@@ -0,0 +1,37 @@
+-- Migration: One answer per inspection question
+
+ALTER TABLE inspection_answers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Storage objects to delete once delete_after has passed
+CREATE TABLE IF NOT EXISTS storage_cleanup (
+    key TEXT PRIMARY KEY,
+    delete_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS storage_cleanup_due_idx ON storage_cleanup (delete_after) WHERE deleted_at IS NULL;
+
+-- Keep only the latest answer to each question before adding the unique key;
+-- the photos of the answers dropped are scheduled for deletion from storage
+WITH ranked AS (
+    SELECT id, row_number() OVER (PARTITION BY inspection_id, question_id ORDER BY created_at DESC, id) AS rn
+    FROM inspection_answers
+), dropped AS (
+    DELETE FROM answer_photos WHERE answer_id IN (SELECT id FROM ranked WHERE rn > 1)
+    RETURNING file_url
+)
+INSERT INTO storage_cleanup (key, delete_after)
+SELECT DISTINCT file_url, CURRENT_TIMESTAMP FROM dropped
+WHERE file_url NOT IN (
+    SELECT ap.file_url FROM answer_photos ap JOIN ranked r ON r.id = ap.answer_id WHERE r.rn = 1
+)
+ON CONFLICT (key) DO NOTHING;
+DELETE FROM inspection_answers WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY inspection_id, question_id ORDER BY created_at DESC, id) AS rn
+        FROM inspection_answers
+    ) ranked WHERE rn > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS inspection_answers_inspection_question_key ON inspection_answers (inspection_id, question_id);
@@ -0,0 +1,6 @@
+-- Migration: Cancelled and abandoned inspections
+
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS inspections_status_idx ON inspections (status);
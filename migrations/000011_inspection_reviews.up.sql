@@ -0,0 +1,22 @@
+-- Migration: Supervisor review of completed inspections
+
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS approved_by VARCHAR(255);
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP WITH TIME ZONE;
+
+-- Reviewer's remark on an answer sent back for rework, cleared by the next review
+ALTER TABLE inspection_answers ADD COLUMN IF NOT EXISTS rework_comment TEXT;
+ALTER TABLE inspection_answers ADD COLUMN IF NOT EXISTS rework_requested_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS inspection_reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    inspection_id UUID NOT NULL REFERENCES inspections(id),
+    decision VARCHAR(50) NOT NULL,
+    reviewer_id UUID NOT NULL REFERENCES admin_users(id),
+    reviewer_name VARCHAR(255) NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    rework JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_reviews_inspection_id ON inspection_reviews(inspection_id);
+CREATE INDEX IF NOT EXISTS inspections_awaiting_review_idx ON inspections (finished_at) WHERE status = 'completed' AND approved_at IS NULL;
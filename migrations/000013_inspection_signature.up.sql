@@ -0,0 +1,5 @@
+-- Migration: Inspector signature captured on completion
+
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS signature_key VARCHAR(500);
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS signed_by VARCHAR(255);
+ALTER TABLE inspections ADD COLUMN IF NOT EXISTS signed_at TIMESTAMP WITH TIME ZONE;
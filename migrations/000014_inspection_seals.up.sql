@@ -0,0 +1,27 @@
+-- Migration: Tamper-evident hash chain over completed inspections
+
+CREATE TABLE IF NOT EXISTS inspection_seals (
+    sequence BIGINT PRIMARY KEY,
+    inspection_id UUID NOT NULL REFERENCES inspections(id),
+    content_hash VARCHAR(64) NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_seals_inspection ON inspection_seals (inspection_id, sequence DESC);
+
+-- Seals can only be appended, like the audit log
+CREATE OR REPLACE FUNCTION inspection_seals_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'inspection_seals is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inspection_seals_no_change ON inspection_seals;
+CREATE TRIGGER inspection_seals_no_change BEFORE UPDATE OR DELETE ON inspection_seals
+    FOR EACH ROW EXECUTE FUNCTION inspection_seals_append_only();
+
+DROP TRIGGER IF EXISTS inspection_seals_no_truncate ON inspection_seals;
+CREATE TRIGGER inspection_seals_no_truncate BEFORE TRUNCATE ON inspection_seals
+    FOR EACH STATEMENT EXECUTE FUNCTION inspection_seals_append_only();
@@ -0,0 +1,10 @@
+-- Migration: EXIF metadata of answer photos
+
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS taken_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS device VARCHAR(255);
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS orientation SMALLINT;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+-- Taken before the inspection started, earlier than the allowed margin
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS stale BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- Migration: Originals and thumbnails of answer photos
+-- file_url stays the upright JPEG shown to viewers
+
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS original_key TEXT;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS thumbnail_key TEXT;
@@ -0,0 +1,27 @@
+-- Migration: Perceptual hashes of answer photos and duplicate flags
+
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+-- Hashes within 3 bits of each other agree exactly in at least one of
+-- their four 16-bit quarters, so similar photos are found by quarter
+CREATE INDEX IF NOT EXISTS answer_photos_phash_q0_idx ON answer_photos (((phash >> 48) & 65535));
+CREATE INDEX IF NOT EXISTS answer_photos_phash_q1_idx ON answer_photos (((phash >> 32) & 65535));
+CREATE INDEX IF NOT EXISTS answer_photos_phash_q2_idx ON answer_photos (((phash >> 16) & 65535));
+CREATE INDEX IF NOT EXISTS answer_photos_phash_q3_idx ON answer_photos ((phash & 65535));
+
+-- Photos near-identical to a photo of another machine
+CREATE TABLE IF NOT EXISTS photo_duplicates (
+    id UUID PRIMARY KEY,
+    photo_key TEXT NOT NULL,
+    photo_thumbnail_key TEXT,
+    inspection_id UUID NOT NULL REFERENCES inspections(id),
+    match_key TEXT NOT NULL,
+    match_thumbnail_key TEXT,
+    match_inspection_id UUID NOT NULL REFERENCES inspections(id),
+    distance SMALLINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (photo_key, match_key)
+);
+
+CREATE INDEX IF NOT EXISTS photo_duplicates_inspection_idx ON photo_duplicates (inspection_id);
+CREATE INDEX IF NOT EXISTS photo_duplicates_match_inspection_idx ON photo_duplicates (match_inspection_id);
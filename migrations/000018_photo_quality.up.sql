@@ -0,0 +1,7 @@
+-- Migration: Quality metrics of answer photos
+
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS sharpness REAL;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS brightness REAL;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS clipped REAL;
+-- Checks of the question the photo failed when it was accepted with a warning
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS quality_issues TEXT[];
@@ -0,0 +1,6 @@
+-- Migration: Similarity of answer photos to the reference images of their question
+
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS reference_similarity REAL;
+ALTER TABLE answer_photos ADD COLUMN IF NOT EXISTS wrong_subject BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS answer_photos_wrong_subject_idx ON answer_photos (answer_id) WHERE wrong_subject;
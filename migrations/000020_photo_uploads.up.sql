@@ -0,0 +1,23 @@
+-- Migration: Resumable photo uploads
+
+-- Photos received in chunks until an answer references them
+CREATE TABLE IF NOT EXISTS photo_uploads (
+    id UUID PRIMARY KEY,
+    inspection_id UUID NOT NULL REFERENCES inspections(id),
+    size BIGINT NOT NULL CHECK (size > 0),
+    received BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS photo_uploads_updated_at_idx ON photo_uploads (updated_at);
+
+-- Each chunk of an upload is a storage object, so receiving a chunk does not
+-- rewrite the chunks before it
+CREATE TABLE IF NOT EXISTS photo_upload_chunks (
+    upload_id UUID NOT NULL REFERENCES photo_uploads(id) ON DELETE CASCADE,
+    start_offset BIGINT NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    key TEXT NOT NULL,
+    PRIMARY KEY (upload_id, start_offset)
+);